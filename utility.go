@@ -18,12 +18,12 @@ const (
 	DARK_CYAN_COLOR = "\x1b[36m"
 	DARK_WHITE_COLOR = "\x1b[37m"
 	BRIGHT_BLACK_COLOR = "\x1b[90m"
-	BRIGHT_RED_COLOR = "\x1b[31m"
-	BRIGHT_GREEN_COLOR = "\x1b[32m"
-	BRIGHT_YELLOW_COLOR = "\x1b[33m"
-	BRIGHT_BLUE_COLOR = "\x1b[34m"
-	BRIGHT_MAGENTA_COLOR = "\x1b[35m"
-	BRIGHT_CYAN_COLOR = "\x1b[36m"
+	BRIGHT_RED_COLOR = "\x1b[91m"
+	BRIGHT_GREEN_COLOR = "\x1b[92m"
+	BRIGHT_YELLOW_COLOR = "\x1b[93m"
+	BRIGHT_BLUE_COLOR = "\x1b[94m"
+	BRIGHT_MAGENTA_COLOR = "\x1b[95m"
+	BRIGHT_CYAN_COLOR = "\x1b[96m"
 	WHITE_COLOR = "\x1b[37m"
 )
 
@@ -0,0 +1,200 @@
+package logger
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// asyncOutput writes the logs to the output of a Logger from a dedicated
+// goroutine, in the same order they were created (see EnableAsyncOutput)
+type asyncOutput struct {
+	m       sync.RWMutex
+	ch      chan asyncEntry
+	closed  bool
+	done    chan struct{}
+	policy  OverflowPolicy
+	dropped atomic.Uint64
+	// nSent and nTaken count the logs queued and the ones taken out of
+	// the buffer, written or dropped, so that flush can wait for them
+	fm     sync.Mutex
+	fc     *sync.Cond
+	nSent  uint64
+	nTaken uint64
+}
+
+// asyncEntry is a log queued in the asynchronous output or, if block
+// is not nil, a block of logs to be written together (see writeBlock)
+type asyncEntry struct {
+	log   Log
+	block []Log
+}
+
+// size returns the number of logs of the entry
+func (e asyncEntry) size() uint64 {
+	if e.block != nil {
+		return uint64(len(e.block))
+	}
+	return 1
+}
+
+func newAsyncOutput(bufferSize int, policy OverflowPolicy, write func(Log), writeBlock func([]Log)) *asyncOutput {
+	a := &asyncOutput{
+		ch:     make(chan asyncEntry, bufferSize),
+		done:   make(chan struct{}),
+		policy: policy,
+	}
+	a.fc = sync.NewCond(&a.fm)
+
+	go func() {
+		defer close(a.done)
+		for e := range a.ch {
+			if e.block != nil {
+				writeBlock(e.block)
+			} else {
+				write(e.log)
+			}
+			a.taken()
+		}
+	}()
+
+	return a
+}
+
+// send queues the entry following the OverflowPolicy: OVERFLOW_DROP_NEWEST
+// and OVERFLOW_DROP_OLDEST drop an entry when the buffer is full, any other
+// policy waits for the buffer to have room. It returns false, without
+// queueing the entry, if the asynchronous output was closed
+func (a *asyncOutput) send(e asyncEntry) bool {
+	a.m.RLock()
+	defer a.m.RUnlock()
+
+	if a.closed {
+		return false
+	}
+
+	switch a.policy {
+	case OVERFLOW_DROP_NEWEST:
+		select {
+		case a.ch <- e:
+			a.queued()
+		default:
+			a.dropped.Add(e.size())
+		}
+	case OVERFLOW_DROP_OLDEST:
+		for {
+			select {
+			case a.ch <- e:
+				a.queued()
+				return true
+			default:
+			}
+
+			select {
+			case old := <-a.ch:
+				a.dropped.Add(old.size())
+				a.taken()
+			default:
+			}
+		}
+	default:
+		a.ch <- e
+		a.queued()
+	}
+	return true
+}
+
+func (a *asyncOutput) queued() {
+	a.fm.Lock()
+	a.nSent ++
+	a.fm.Unlock()
+}
+
+func (a *asyncOutput) taken() {
+	a.fm.Lock()
+	a.nTaken ++
+	a.fm.Unlock()
+	a.fc.Broadcast()
+}
+
+// flush waits for the logs queued so far to be written,
+// while the new ones can still be queued
+func (a *asyncOutput) flush() {
+	a.fm.Lock()
+	defer a.fm.Unlock()
+
+	for target := a.nSent; a.nTaken < target; {
+		a.fc.Wait()
+	}
+}
+
+// close stops accepting new logs and waits for the
+// queued ones to be written
+func (a *asyncOutput) close() {
+	a.m.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.ch)
+	}
+	a.m.Unlock()
+
+	<-a.done
+}
+
+// EnableAsyncOutput makes the Logger write the logs to its output from a
+// dedicated goroutine, through a buffer of the given size, so that creating
+// a log never waits for a slow output, like a network connection. The logs
+// are written in the order they are created. When the buffer is full, the
+// new log is not written to the output (it is still stored), unless a
+// different policy is set with SetAsyncOutputPolicy. Close (or
+// DisableAsyncOutput) writes the logs still in the buffer before
+// returning. Clones do not inherit it
+func (o *output) EnableAsyncOutput(bufferSize int) {
+	o.am.Lock()
+	defer o.am.Unlock()
+
+	o.disableAsyncOutput()
+	o.async.Store(newAsyncOutput(bufferSize, o.asyncPolicy, o.writeOut, o.writeBlockOut))
+}
+
+// DisableAsyncOutput writes the logs still in the buffer of the
+// asynchronous output, if enabled, and goes back to writing
+// the logs synchronously
+func (o *output) DisableAsyncOutput() {
+	o.am.Lock()
+	defer o.am.Unlock()
+
+	o.disableAsyncOutput()
+}
+
+// disableAsyncOutput is DisableAsyncOutput without locking
+func (o *output) disableAsyncOutput() {
+	a := o.async.Swap(nil)
+	if a == nil {
+		return
+	}
+
+	a.close()
+	o.asyncDropped.Add(a.dropped.Load())
+}
+
+// SetAsyncOutputPolicy sets what happens when the buffer of the
+// asynchronous output is full: with OVERFLOW_DROP_NEWEST (the default)
+// and OVERFLOW_DROP_OLDEST a log is not written (see AsyncOutputDropped),
+// while with OVERFLOW_BLOCK and OVERFLOW_GROW the new log waits for
+// room. It applies from the next EnableAsyncOutput
+func (o *output) SetAsyncOutputPolicy(policy OverflowPolicy) {
+	o.am.Lock()
+	defer o.am.Unlock()
+
+	o.asyncPolicy = policy
+}
+
+// AsyncOutputDropped returns the number of logs not written to the
+// output because the buffer of the asynchronous output was full
+func (o *output) AsyncOutputDropped() uint64 {
+	dropped := o.asyncDropped.Load()
+	if a := o.async.Load(); a != nil {
+		dropped += a.dropped.Load()
+	}
+	return dropped
+}
@@ -0,0 +1,128 @@
+package logger
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingWriter counts the lines written to it
+type countingWriter struct {
+	m     sync.Mutex
+	lines int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+	w.lines += bytes.Count(p, []byte{'\n'})
+	return len(p), nil
+}
+
+func (w *countingWriter) count() int {
+	w.m.Lock()
+	defer w.m.Unlock()
+	return w.lines
+}
+
+func TestAsyncOutputToggleWhileLogging(t *testing.T) {
+	w := new(countingWriter)
+	l := NewLogger(w)
+	l.SetAsyncOutputPolicy(OVERFLOW_BLOCK)
+
+	const goroutines, logs = 4, 500
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < logs; i++ {
+				l.Print(LOG_LEVEL_INFO, "message")
+			}
+		}()
+	}
+
+	for i := 0; i < 50; i++ {
+		l.EnableAsyncOutput(8)
+		l.AsyncOutputDropped()
+		l.DisableAsyncOutput()
+	}
+	wg.Wait()
+	l.DisableAsyncOutput()
+
+	if n := w.count(); n != goroutines * logs {
+		t.Errorf("written %d logs, want %d", n, goroutines * logs)
+	}
+	if dropped := l.AsyncOutputDropped(); dropped != 0 {
+		t.Errorf("AsyncOutputDropped = %d, want 0", dropped)
+	}
+}
+
+func TestFlushUnderLoad(t *testing.T) {
+	w := &slowWriter{ delay: 50 * time.Microsecond }
+	dir := t.TempDir()
+	l, err := NewHugeLogger(w, dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	l.SetAsyncOutputPolicy(OVERFLOW_BLOCK)
+	l.EnableAsyncOutput(64)
+
+	const goroutines, logs = 4, 250
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < logs; i++ {
+				l.Printf(LOG_LEVEL_INFO, "log %d-%d", g, i)
+			}
+		}(g)
+	}
+
+	// Flush must be safe while the logs are being created
+	stop := make(chan struct{})
+	flushed := make(chan struct{})
+	go func() {
+		defer close(flushed)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				if err := l.Flush(); err != nil {
+					t.Error(err)
+				}
+			}
+		}
+	}()
+	wg.Wait()
+	close(stop)
+	<-flushed
+
+	if err := l.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := strings.Count(w.String(), "\n"); n != goroutines * logs {
+		t.Errorf("written %d logs to the output, want %d", n, goroutines * logs)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*." + LogFileExtension))
+	var stored int
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored += bytes.Count(b, []byte{ '\n' })
+	}
+	if stored != goroutines * logs {
+		t.Errorf("found %d logs on disk, want %d", stored, goroutines * logs)
+	}
+}
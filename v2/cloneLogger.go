@@ -3,82 +3,414 @@ package logger
 import (
 	"fmt"
 	"io"
-	"os"
+	"sort"
+	"time"
 )
 
 type cloneLogger struct {
+	output
+	broadcaster
+	tagCounter
+	blankLines
+	partialLine
+	hooks
+	volumeAlert
+	redactors
+	sampler
+	deduplicator
 	parent Logger
 	tags []string
 	logs []int
-	out io.Writer
-	disableExtras  bool
+	caller bool
+	requiredFields []string
+	minLevel LogLevel
+	fatalMode FatalMode
+	sanitizeMessage bool
+	secondary Logger
+	nRemoved int
 }
 
 func (l *cloneLogger) newLog(log Log, writeOutput bool) int {
+	if !log.l.internal && !levelEnabled(log.Level(), l.minLevel) {
+		return -1
+	}
+	if !l.sample(l, log) {
+		return -1
+	}
+	if l.sanitizeMessage {
+		log.l.message = sanitizeMessage(log.l.message)
+	}
+	l.redact(log)
 	log.addTags(l.tags...)
+	if repeat, collapse := l.dedup(l, log); repeat {
+		if collapse {
+			return -1
+		}
+		writeOutput = false
+	}
+
+	// the logs of a BufferedScope are written by the parent only when flushed
+	_, scoped := l.out.(*scopeBuffer)
 
 	var p int
-	if writeOutput && l.out != nil && l.out == l.parent.Out() {
+	if writeOutput && l.out != nil && (l.out == l.parent.Out() || scoped) {
 		p = l.parent.newLog(log, false)
 	} else {
 		p = l.parent.newLog(log, writeOutput)
 	}
+	if p < 0 {
+		// dropped by the Logger it was cloned from
+		return -1
+	}
 
-	l.logs = append(l.logs, p)
+	l.logs = append(l.logs, p + l.parent.removed())
 	p = len(l.logs) - 1
+	l.count(log)
+	l.checkVolume()
+	l.dispatch(log)
+	l.runHooks(log)
+
+	if l.secondary != nil {
+		l.tee(log)
+	}
+
+	if missing := missingFields(log, l.requiredFields); len(missing) > 0 {
+		defer l.newLog(missingFieldsWarning(log, missing), writeOutput)
+	}
 
 	if l.out == nil || !writeOutput {
 		return p
 	}
 
-	out := l.out
-	if level := log.Level(); out == os.Stdout && (level == LOG_LEVEL_WARNING || level == LOG_LEVEL_ERROR || level == LOG_LEVEL_FATAL) {
-		out = os.Stderr
+	l.logToOut(log)
+	return p
+}
+
+func (l *cloneLogger) AddLog(level LogLevel, message string, extra string, writeOutput bool) {
+	l.newLog(createLog(level, message, extra, l.caller), writeOutput)
+}
+
+func (l *cloneLogger) AddLogs(logs []Log, writeOutput bool) []int {
+	res := make([]int, len(logs))
+	batch := make([]Log, 0, len(logs))
+	pos := make([]int, 0, len(logs))
+
+	for i, log := range logs {
+		res[i] = -1
+
+		log = log.copy()
+		if !levelEnabled(log.Level(), l.minLevel) {
+			continue
+		}
+		if l.sanitizeMessage {
+			log.l.message = sanitizeMessage(log.l.message)
+		}
+		l.redact(log)
+		log.addTags(l.tags...)
+
+		batch = append(batch, log)
+		pos = append(pos, i)
 	}
 
-	if ToTerminal(l.out) {
-		if log.l.extra != "" && !l.disableExtras {
-			fmt.Fprintln(out, log.l.fullColored())
-		} else {
-			fmt.Fprintln(out, log.l.colored())
+	_, scoped := l.out.(*scopeBuffer)
+	parentOutput := writeOutput && !(l.out != nil && (l.out == l.parent.Out() || scoped))
+
+	ps := l.parent.AddLogs(batch, parentOutput)
+	removed := l.parent.removed()
+
+	for j, p := range ps {
+		if p < 0 {
+			continue
 		}
-	} else {
-		if log.l.extra != "" && !l.disableExtras {
-			fmt.Fprintln(out, log.l.full())
-		} else {
-			fmt.Fprintln(out, log.l.String())
+		log := batch[j]
+
+		l.logs = append(l.logs, p + removed)
+		res[pos[j]] = len(l.logs) - 1
+
+		l.count(log)
+		l.checkVolume()
+		l.dispatch(log)
+		l.runHooks(log)
+
+		if l.secondary != nil {
+			l.tee(log)
+		}
+
+		if l.out != nil && writeOutput {
+			l.logToOut(log)
+		}
+		if missing := missingFields(log, l.requiredFields); len(missing) > 0 {
+			l.newLog(missingFieldsWarning(log, missing), writeOutput)
 		}
 	}
 
-	return p
+	return res
 }
 
-func (l *cloneLogger) AddLog(level LogLevel, message string, extra string, writeOutput bool) {
-	l.newLog(Log{
-		l: newLog(level, message, extra),
-	}, writeOutput)
+func (l *cloneLogger) AddLogFields(level LogLevel, message string, fields map[string]any, writeOutput bool) int {
+	return addLogFields(l, level, message, fields, writeOutput, l.caller)
+}
+
+func (l *cloneLogger) AddError(level LogLevel, err error) {
+	addError(l, level, err, l.caller)
+}
+
+func (l *cloneLogger) addBlob(id string, blob []byte) error {
+	return l.parent.addBlob(id, blob)
+}
+
+func (l *cloneLogger) AddLogWithBlob(level LogLevel, message string, blob []byte, writeOutput bool) (int, error) {
+	return addLogWithBlob(l, level, message, blob, writeOutput, l.caller)
+}
+
+func (l *cloneLogger) AddLogWithTTL(level LogLevel, message string, extra string, writeOutput bool, ttl time.Duration) (int, error) {
+	return addLogWithTTL(l, level, message, extra, writeOutput, ttl, l.caller)
+}
+
+func (l *cloneLogger) canExpire() bool {
+	return l.parent.canExpire()
+}
+
+func (l *cloneLogger) setTTL(index int, ttl time.Duration) error {
+	p, err := l.toParent(l.logs[index:index+1])
+	if err != nil {
+		return err
+	}
+	return l.parent.setTTL(p[0], ttl)
+}
+
+func (l *cloneLogger) GetBlob(id string) ([]byte, error) {
+	return l.parent.GetBlob(id)
 }
 
 func (l *cloneLogger) Clone(out io.Writer, tags ...string) Logger {
 	return &cloneLogger{
-		out:  out,
-		tags: tags,
-		disableExtras: l.disableExtras,
+		output: l.clone(out),
+		tags:   tags,
 		parent: l,
+		caller: l.caller,
+	}
+}
+
+func (l *cloneLogger) BufferedScope() (Logger, func()) {
+	return bufferedScope(l, &l.output)
+}
+
+func (l *cloneLogger) Tee(secondary Logger) Logger {
+	return tee(l, l.caller, secondary)
+}
+
+// tee forwards a copy of the log to the secondary Logger. If the secondary
+// panics, the failure is reported with an error log on the parent only
+func (l *cloneLogger) tee(log Log) {
+	err := PanicToErr(func() error {
+		l.secondary.newLog(log.copy(), false)
+		return nil
+	})
+	if err != nil {
+		l.parent.newLog(newInternalLog(LOG_LEVEL_ERROR, "Tee: secondary Logger failed", err.Error()), true)
+	}
+}
+
+// Enabled reports whether a log with the given level would be stored
+// both by the clone and by the Logger it was cloned from
+func (l *cloneLogger) Enabled(level LogLevel) bool {
+	return levelEnabled(level, l.minLevel) && l.parent.Enabled(level)
+}
+
+// Name always returns an empty string, since
+// clones are never registered (see NewNamedLogger)
+func (l *cloneLogger) Name() string {
+	return ""
+}
+
+// MinLevel returns the threshold of the clone (see SetMinLevel)
+func (l *cloneLogger) MinLevel() LogLevel {
+	return l.minLevel
+}
+
+// SetMinLevel sets the threshold of the clone, which is independent
+// from the one of the Logger it was cloned from
+func (l *cloneLogger) SetMinLevel(level LogLevel) {
+	l.minLevel = level
+}
+
+// OutputLevel is the same as MinLevel
+func (l *cloneLogger) OutputLevel() LogLevel {
+	return l.MinLevel()
+}
+
+// SetOutputLevel is the same as SetMinLevel
+func (l *cloneLogger) SetOutputLevel(level LogLevel) {
+	l.SetMinLevel(level)
+}
+
+// RequireFields is like the one of the Logger it was cloned from, but the check
+// is independent: the required fields of the parent are checked by the parent
+func (l *cloneLogger) RequireFields(keys ...string) {
+	l.requiredFields = keys
+}
+
+// Close only logs the partial line left by Write and writes the logs
+// still in the buffer of the asynchronous output of the clone, if enabled
+// (see EnableAsyncOutput), since a clone does not own the storage: the
+// Logger it was cloned from must be closed
+func (l *cloneLogger) Close() error {
+	flushPartialLine(l, &l.blankLines, &l.partialLine)
+	l.flushRepeated(l)
+	l.reportDropped(l)
+	l.DisableAsyncOutput()
+	return nil
+}
+
+func (l *cloneLogger) EnableDeduplication(window time.Duration, collapse bool) {
+	l.enableDeduplication(l, window, collapse)
+}
+
+func (l *cloneLogger) DisableDeduplication() {
+	l.disableDeduplication(l)
+}
+
+// Flush flushes the output of the clone and then
+// the Logger it was cloned from (see Logger.Flush)
+func (l *cloneLogger) Flush() error {
+	flushPartialLine(l, &l.blankLines, &l.partialLine)
+	l.flushRepeated(l)
+	l.reportDropped(l)
+	l.flushOutput()
+	return l.parent.Flush()
+}
+
+func (l *cloneLogger) EnableCaller() {
+	l.caller = true
+}
+
+func (l *cloneLogger) DisableCaller() {
+	l.caller = false
+}
+
+func (l *cloneLogger) callerEnabled() bool {
+	return l.caller
+}
+
+// EnableSequence enables the sequence on the parent Logger,
+// since the sequence is the index of the log in the shared storage
+func (l *cloneLogger) EnableSequence() {
+	l.parent.EnableSequence()
+}
+
+func (l *cloneLogger) DisableSequence() {
+	l.parent.DisableSequence()
+}
+
+// SetCompressRotated changes the compression of the Logger
+// it was cloned from (see Logger.SetCompressRotated)
+func (l *cloneLogger) SetCompressRotated(compress bool) {
+	l.parent.SetCompressRotated(compress)
+}
+
+// SetRetention changes the retention of the Logger
+// it was cloned from (see Logger.SetRetention)
+func (l *cloneLogger) SetRetention(maxChunks int) {
+	l.parent.SetRetention(maxChunks)
+}
+
+// SetMaxAge changes the retention of the Logger
+// it was cloned from (see Logger.SetMaxAge)
+func (l *cloneLogger) SetMaxAge(d time.Duration) {
+	l.parent.SetMaxAge(d)
+}
+
+// SetFallback sets the fallback of the Logger it was cloned
+// from, which owns the storage (see Logger.SetFallback)
+func (l *cloneLogger) SetFallback(fallback Logger) {
+	l.parent.SetFallback(fallback)
+}
+
+// SetMaxFileBytes changes the rollover size of the Logger
+// it was cloned from (see Logger.SetMaxFileBytes)
+func (l *cloneLogger) SetMaxFileBytes(n int64) {
+	l.parent.SetMaxFileBytes(n)
+}
+
+// SetSyncInterval changes the sync interval of the
+// Logger it was cloned from (see Logger.SetSyncInterval)
+func (l *cloneLogger) SetSyncInterval(d time.Duration) {
+	l.parent.SetSyncInterval(d)
+}
+
+// SetSyncPolicy changes the sync policy of the
+// Logger it was cloned from (see Logger.SetSyncPolicy)
+func (l *cloneLogger) SetSyncPolicy(policy SyncPolicy) {
+	l.parent.SetSyncPolicy(policy)
+}
+
+// toParent converts the stored indexes of the logs into the indexes they
+// have in the Logger it was cloned from, which change when it removes
+// logs (see TruncateToLast). A removed log is reported as ErrLogNotFound
+func (l *cloneLogger) toParent(logs []int) ([]int, error) {
+	removed := l.parent.removed()
+
+	res := make([]int, len(logs))
+	for i, p := range logs {
+		if p < removed {
+			return nil, fmt.Errorf("%w: log removed from the Logger it was cloned from", ErrLogNotFound)
+		}
+		res[i] = p - removed
 	}
+	return res, nil
+}
+
+func (l *cloneLogger) removed() int {
+	return l.nRemoved
 }
 
-func (l *cloneLogger) DisableExtras() {
-	l.disableExtras = true
+// FirstIndex returns the index of the oldest log of the clone
+// that can still be read from the Logger it was cloned from
+// (see Logger.FirstIndex)
+func (l *cloneLogger) FirstIndex() int {
+	first := l.parent.FirstIndex() + l.parent.removed()
+	return sort.SearchInts(l.logs, first)
 }
 
-func (l *cloneLogger) EnableExtras() {
-	l.disableExtras = false
+// TruncateToLast removes from the clone all but its last n logs, which
+// get the indexes from 0. The logs are not removed from the Logger it
+// was cloned from
+func (l *cloneLogger) TruncateToLast(n int) error {
+	if n < 0 {
+		return fmt.Errorf("invalid number of logs to keep: %d", n)
+	}
+
+	cut := len(l.logs) - n
+	if cut <= 0 {
+		return nil
+	}
+
+	logs := make([]int, n)
+	copy(logs, l.logs[cut:])
+	l.logs = logs
+	l.nRemoved += cut
+	return nil
 }
 
 func (l *cloneLogger) GetLog(index int) Log {
-	p := l.logs[index]
-	return l.parent.GetLog(p)
+	log, err := l.GetLogE(index)
+	if err != nil {
+		panic(err)
+	}
+	return log
+}
+
+func (l *cloneLogger) GetLogE(index int) (Log, error) {
+	if err := checkIndex(index, len(l.logs)); err != nil {
+		return Log{}, err
+	}
+
+	p, err := l.toParent(l.logs[index:index+1])
+	if err != nil {
+		return Log{}, err
+	}
+	return l.parent.GetLogE(p[0])
 }
 
 func (l *cloneLogger) GetLastNLogs(n int) []Log {
@@ -90,25 +422,163 @@ func (l *cloneLogger) GetLastNLogs(n int) []Log {
 }
 
 func (l *cloneLogger) GetLogs(start int, end int) []Log {
-	logsToParent := make([]int, 0, end-start)
-	logsToParent = append(logsToParent, l.logs[start:end]...)
-	return l.parent.GetSpecificLogs(logsToParent)
+	logs, err := l.GetLogsE(start, end)
+	if err != nil {
+		panic(err)
+	}
+	return logs
+}
+
+func (l *cloneLogger) GetLogsE(start int, end int) ([]Log, error) {
+	if err := checkRange(start, end, len(l.logs)); err != nil {
+		return nil, err
+	}
+
+	logsToParent, err := l.toParent(l.logs[start:end])
+	if err != nil {
+		return nil, err
+	}
+	return l.parent.GetSpecificLogsE(logsToParent)
+}
+
+func (l *cloneLogger) GetLogsByTimeRange(start time.Time, end time.Time) []Log {
+	return getLogsByTimeRange(l, start, end)
+}
+
+func (l *cloneLogger) IndexAt(t time.Time) int {
+	return indexAt(l, t)
+}
+
+func (l *cloneLogger) ReverseCursor(from int) func(n int) []Log {
+	return reverseCursor(l, from)
+}
+
+func (l *cloneLogger) GetLogsSortedBySeverity(start int, end int) []Log {
+	return sortedBySeverity(l.GetLogs(start, end))
+}
+
+func (l *cloneLogger) ForEachLog(start int, end int, fn func(i int, log Log) bool) error {
+	return forEachLog(l, start, end, fn)
+}
+
+func (l *cloneLogger) GetLogsBuffered(start int, end int) (<-chan []Log, func() error) {
+	if end <= start {
+		return streamLogs(0, nil)
+	}
+	if err := checkRange(start, end, len(l.logs)); err != nil {
+		// the error is reported by the stop function, like the read errors
+		return streamLogs(1, func(int) ([]Log, error) {
+			return nil, err
+		})
+	}
+
+	logsToParent := l.logs[start:end]
+	batches := (len(logsToParent) + LogChunkSize - 1) / LogChunkSize
+
+	return streamLogs(batches, func(batch int) ([]Log, error) {
+		bStart := batch * LogChunkSize
+		bEnd := bStart + LogChunkSize
+		if bEnd > len(logsToParent) {
+			bEnd = len(logsToParent)
+		}
+
+		batchToParent, err := l.toParent(logsToParent[bStart:bEnd])
+		if err != nil {
+			return nil, err
+		}
+		return l.parent.GetSpecificLogsE(batchToParent)
+	})
+}
+
+func (l *cloneLogger) ReplayToOutput(start int, end int) error {
+	return replayToOutput(l, l.logToOut, start, end, 0)
+}
+
+func (l *cloneLogger) ReplayToOutputPaced(start int, end int, pace time.Duration) error {
+	return replayToOutput(l, l.logToOut, start, end, pace)
+}
+
+func (l *cloneLogger) LogsReader(start int, end int) io.ReadCloser {
+	return newLogsReader(l, start, end)
+}
+
+func (l *cloneLogger) ExportCSV(w io.Writer) error {
+	return exportCSV(l, w)
+}
+
+func (l *cloneLogger) ExportGroupedJSON(w io.Writer, levels ...LogLevel) error {
+	return exportGroupedJSON(l, w, levels...)
+}
+
+func (l *cloneLogger) FilterLogs(match func(Log) bool) ([]Log, error) {
+	return filterLogs(l, match)
+}
+
+func (l *cloneLogger) FilterLogsBuffered(match func(Log) bool) (<-chan []Log, func() error) {
+	return filterLogsBuffered(l, match)
+}
+
+func (l *cloneLogger) FilterByTags(tags ...string) ([]Log, error) {
+	return filterLogs(l, func(log Log) bool {
+		return log.Match(tags...)
+	})
+}
+
+func (l *cloneLogger) FilterByLevels(levels ...LogLevel) ([]Log, error) {
+	return filterLogs(l, func(log Log) bool {
+		return log.LevelMatchAny(levels...)
+	})
+}
+
+func (l *cloneLogger) SearchLogs(pattern string) ([]Log, error) {
+	return searchLogs(l, pattern)
+}
+
+func (l *cloneLogger) ExportJSONL(w io.Writer) (int, error) {
+	return exportJSONL(l, w)
+}
+
+func (l *cloneLogger) ImportJSONL(r io.Reader) error {
+	return importJSONL(l, r)
+}
+
+func (l *cloneLogger) StreamLevelLogs(w io.Writer, levels ...LogLevel) error {
+	return streamLevelLogs(l, w, levels...)
 }
 
 func (l *cloneLogger) GetSpecificLogs(logs []int) []Log {
-	logsToParent := make([]int, 0, len(logs))
+	res, err := l.GetSpecificLogsE(logs)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (l *cloneLogger) GetSpecificLogsE(logs []int) ([]Log, error) {
+	stored := make([]int, 0, len(logs))
 	for _, p := range logs {
-		logsToParent = append(logsToParent, l.logs[p])
+		if err := checkIndex(p, len(l.logs)); err != nil {
+			return nil, err
+		}
+		stored = append(stored, l.logs[p])
+	}
+
+	logsToParent, err := l.toParent(stored)
+	if err != nil {
+		return nil, err
 	}
-	return l.parent.GetSpecificLogs(logsToParent)
+	return l.parent.GetSpecificLogsE(logsToParent)
 }
 
-func (l *cloneLogger) NLogs() int {
-	return len(l.logs)
+// SetSanitizeMessage is like the one of the Logger it was cloned from, but
+// the setting is independent: when enabled on the parent, the logs of the
+// clone are sanitized by the parent anyway
+func (l *cloneLogger) SetSanitizeMessage(sanitize bool) {
+	l.sanitizeMessage = sanitize
 }
 
-func (l *cloneLogger) Out() io.Writer {
-	return l.out
+func (l *cloneLogger) NLogs() int {
+	return len(l.logs)
 }
 
 func (l *cloneLogger) Print(level LogLevel, a ...any) {
@@ -116,6 +586,9 @@ func (l *cloneLogger) Print(level LogLevel, a ...any) {
 }
 
 func (l *cloneLogger) Printf(level LogLevel, format string, a ...any) {
+	if !l.Enabled(level) {
+		return
+	}
 	l.Print(level, fmt.Sprintf(format, a...))
 }
 
@@ -123,6 +596,30 @@ func (l *cloneLogger) Debug(a ...any) {
 	l.Print(LOG_LEVEL_DEBUG, a...)
 }
 
+func (l *cloneLogger) Fatal(a ...any) {
+	fatal(l, l.fatalMode, fatalArgs(a...))
+}
+
+func (l *cloneLogger) Fatalf(format string, a ...any) {
+	fatal(l, l.fatalMode, fmt.Sprintf(format, a...))
+}
+
+func (l *cloneLogger) SetFatalExits(exit bool) {
+	if exit {
+		l.fatalMode = FATAL_EXIT
+	} else {
+		l.fatalMode = FATAL_CONTINUE
+	}
+}
+
+func (l *cloneLogger) SetFatalMode(mode FatalMode) {
+	l.fatalMode = mode
+}
+
 func (l *cloneLogger) Write(p []byte) (n int, err error) {
-	return write(l, p)
+	return write(l, p, &l.blankLines, &l.partialLine)
+}
+
+func (l *cloneLogger) ReadFrom(r io.Reader) (n int64, err error) {
+	return readFrom(l, r, &l.blankLines, &l.partialLine)
 }
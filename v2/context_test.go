@@ -0,0 +1,30 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestFromContextDefault(t *testing.T) {
+	if l := FromContext(context.Background()); l != DefaultLogger {
+		t.Errorf("FromContext without a Logger = %v, want DefaultLogger", l)
+	}
+}
+
+func TestContextKeepsClone(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger(nil)
+	clone := l.Clone(&buf, "request-1")
+
+	got := FromContext(NewContext(context.Background(), clone))
+	if got != clone {
+		t.Fatalf("FromContext = %v, want the stored clone", got)
+	}
+
+	got.Print(LOG_LEVEL_INFO, "handled")
+	logs := l.GetLastNLogs(1)
+	if len(logs) != 1 || !logs[0].Match("request-1") {
+		t.Errorf("log not tagged by the clone: %v", logs)
+	}
+}
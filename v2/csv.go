@@ -0,0 +1,69 @@
+package logger
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+	"time"
+)
+
+// csvHeader is the header row written by LogsToCSV and ExportCSV
+var csvHeader = []string{ "id", "date", "level", "message", "extra", "tags" }
+
+// csvRecord returns the row of the log: the date is in RFC 3339
+// format, the message and the extra are without the terminal
+// colors and the tags are joined with ';'
+func csvRecord(log Log) []string {
+	return []string{
+		log.ID(),
+		log.Date().Format(time.RFC3339Nano),
+		log.Level().Name(),
+		log.Message(),
+		log.Extra(),
+		strings.Join(log.Tags(), ";"),
+	}
+}
+
+// LogsToCSV writes the logs to w in CSV format, for example to open them
+// in a spreadsheet: a header row (id, date, level, message, extra, tags)
+// is followed by a row for each log, with the date in RFC 3339 format,
+// the message and the extra without the terminal colors and the tags
+// joined with ';'. The fields with newlines, like most extras, are quoted
+func LogsToCSV(w io.Writer, logs []Log) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, log := range logs {
+		if err := cw.Write(csvRecord(log)); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func exportCSV(l Logger, w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	ch, stop := l.GetLogsBuffered(l.FirstIndex(), l.NLogs())
+	for logs := range ch {
+		for _, log := range logs {
+			if err := cw.Write(csvRecord(log)); err != nil {
+				stop()
+				return err
+			}
+		}
+	}
+	if err := stop(); err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
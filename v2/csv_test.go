@@ -0,0 +1,55 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	setChunkSize(t, 2)
+	l, _ := newTestHugeLogger(t)
+	l.AddLog(LOG_LEVEL_INFO, "plain", "", false)
+	l.AddLog(LOG_LEVEL_ERROR, DARK_RED_COLOR + "colored" + DEFAULT_COLOR, "first line\nsecond, \"quoted\" line", false)
+	l.Clone(nil, "a", "b").AddLog(LOG_LEVEL_WARNING, "tagged", "", false)
+
+	var buf bytes.Buffer
+	if err := l.ExportCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("read %d records, want the header and 3 logs", len(records))
+	}
+	for i, record := range records {
+		if len(record) != len(csvHeader) {
+			t.Errorf("record %d has %d fields, want %d", i, len(record), len(csvHeader))
+		}
+	}
+
+	if got := records[2][3]; got != "colored" {
+		t.Errorf("message = %q, want it without colors", got)
+	}
+	if got, want := records[2][4], "first line\nsecond, \"quoted\" line"; got != want {
+		t.Errorf("extra = %q, want %q", got, want)
+	}
+	if got := records[3][5]; got != "a;b" {
+		t.Errorf("tags = %q, want a;b", got)
+	}
+
+	logs := l.GetLastNLogs(3)
+	var direct bytes.Buffer
+	if err := LogsToCSV(&direct, logs); err != nil {
+		t.Fatal(err)
+	}
+	if records[0][0] != "id" || records[1][0] != logs[0].ID() {
+		t.Errorf("unexpected header or id: %v", records[:2])
+	}
+	if got, _ := csv.NewReader(&direct).ReadAll(); len(got) != 4 {
+		t.Errorf("LogsToCSV wrote %d records, want 4", len(got))
+	}
+}
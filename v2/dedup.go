@@ -0,0 +1,110 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// deduplicator coalesces the consecutive identical logs
+// (see EnableDeduplication)
+type deduplicator struct {
+	dm       sync.Mutex
+	window   time.Duration
+	collapse bool
+	key      string
+	level    LogLevel
+	last     time.Time
+	hasLast  bool
+	repeated int
+}
+
+func (d *deduplicator) enableDeduplication(l Logger, window time.Duration, collapse bool) {
+	d.disableDeduplication(l)
+
+	d.dm.Lock()
+	defer d.dm.Unlock()
+
+	d.window = window
+	d.collapse = collapse
+}
+
+func (d *deduplicator) disableDeduplication(l Logger) {
+	d.dm.Lock()
+	n, level := d.endStreak()
+	d.window = 0
+	d.dm.Unlock()
+
+	reportRepeated(l, level, n)
+}
+
+// dedup reports whether the log repeats the previous one, in which case
+// it's not written to the output, and whether it must not be stored either.
+// When a streak of repeated logs ends, because a different log arrives or
+// it arrives after the window elapsed, a log with the number of repetitions
+// is created. The streak is also reported by flushRepeated: no timer is
+// used, so that the report is always created by the goroutine logging
+func (d *deduplicator) dedup(l Logger, log Log) (repeat bool, collapse bool) {
+	if log.l.internal {
+		return false, false
+	}
+
+	d.dm.Lock()
+	if d.window <= 0 {
+		d.dm.Unlock()
+		return false, false
+	}
+
+	key := log.Level().Name() + "\x00" + log.l.message + "\x00" + strings.Join(log.tags, "\x00")
+	now := time.Now()
+
+	if d.hasLast && key == d.key && now.Sub(d.last) < d.window {
+		d.last = now
+		d.repeated ++
+
+		collapse = d.collapse
+		d.dm.Unlock()
+		return true, collapse
+	}
+
+	n, level := d.endStreak()
+	d.key, d.level, d.last, d.hasLast = key, log.Level(), now, true
+	d.dm.Unlock()
+
+	reportRepeated(l, level, n)
+	return false, false
+}
+
+// flushRepeated ends the current streak, reporting it
+// (see Logger.Flush)
+func (d *deduplicator) flushRepeated(l Logger) {
+	d.dm.Lock()
+	n, level := d.endStreak()
+	d.dm.Unlock()
+
+	reportRepeated(l, level, n)
+}
+
+// endStreak forgets the last log and returns how many
+// times it was repeated, and its level
+func (d *deduplicator) endStreak() (int, LogLevel) {
+	n, level := d.repeated, d.level
+
+	d.hasLast = false
+	d.repeated = 0
+
+	return n, level
+}
+
+func reportRepeated(l Logger, level LogLevel, n int) {
+	if n == 0 {
+		return
+	}
+
+	l.newLog(newInternalLog(
+		level,
+		fmt.Sprintf("last message repeated %d times", n),
+		"",
+	), true)
+}
@@ -0,0 +1,83 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeduplicationBurst(t *testing.T) {
+	for _, collapse := range []bool{ false, true } {
+		var sb strings.Builder
+		l := NewLogger(&sb)
+		l.SetFormatter(messageFormatter{})
+		l.EnableDeduplication(time.Minute, collapse)
+
+		for i := 0; i < 100; i++ {
+			l.Print(LOG_LEVEL_ERROR, "flapping")
+		}
+		l.Print(LOG_LEVEL_ERROR, "recovered")
+
+		if got, want := sb.String(), "flapping\nlast message repeated 99 times\nrecovered\n"; got != want {
+			t.Errorf("collapse %v: output %q, want %q", collapse, got, want)
+		}
+
+		want := 102
+		if collapse {
+			want = 3
+		}
+		if n := l.NLogs(); n != want {
+			t.Errorf("collapse %v: NLogs = %d, want %d", collapse, n, want)
+		}
+	}
+}
+
+func TestDeduplicationWindow(t *testing.T) {
+	l := NewLogger(nil)
+	l.EnableDeduplication(20 * time.Millisecond, true)
+
+	for i := 0; i < 5; i++ {
+		l.Print(LOG_LEVEL_WARNING, "flapping")
+	}
+	time.Sleep(40 * time.Millisecond)
+	l.Print(LOG_LEVEL_WARNING, "flapping")
+
+	got := messages(l.GetLastNLogs(l.NLogs()))
+	want := []string{ "flapping", "last message repeated 4 times", "flapping" }
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("logs after the window elapsed %q, want %q", got, want)
+	}
+	if level := l.GetLog(1).Level(); level != LOG_LEVEL_WARNING {
+		t.Errorf("report level = %v, want WARNING", level)
+	}
+
+	l.Print(LOG_LEVEL_WARNING, "flapping")
+	l.Flush()
+	if last := l.GetLastNLogs(1)[0].Message(); last != "last message repeated 1 times" {
+		t.Errorf("last log after Flush %q, want the streak reported", last)
+	}
+}
+
+// TestDeduplicationCloneRace checks that the streaks of a clone are
+// reported by the goroutine logging: run it with -race
+func TestDeduplicationCloneRace(t *testing.T) {
+	var sb strings.Builder
+	l := NewLogger(nil)
+	c := l.Clone(&sb)
+	c.SetFormatter(messageFormatter{})
+	c.EnableDeduplication(10 * time.Millisecond, false)
+
+	for i := 0; i < 10; i++ {
+		c.Print(LOG_LEVEL_ERROR, "flapping")
+		c.Print(LOG_LEVEL_ERROR, "flapping")
+		time.Sleep(20 * time.Millisecond)
+	}
+	c.Flush()
+
+	if got, want := strings.Count(sb.String(), "last message repeated 1 times\n"), 10; got != want {
+		t.Errorf("%d streaks reported, want %d: %q", got, want, sb.String())
+	}
+	if got, want := c.NLogs(), 30; got != want {
+		t.Errorf("the clone has %d logs, want %d", got, want)
+	}
+}
@@ -0,0 +1,145 @@
+package logger
+
+// DiffMode defines which parts of the logs are used by DiffLogsBy
+// to align two slices of logs
+type DiffMode int
+
+const (
+	DIFF_BY_MESSAGE           DiffMode = iota // DIFF_BY_MESSAGE aligns the logs by their message only
+	DIFF_BY_MESSAGE_AND_LEVEL                 // DIFF_BY_MESSAGE_AND_LEVEL aligns the logs by their message and level
+)
+
+// LogDiffType tells how a log differs between two slices of logs
+type LogDiffType int
+
+const (
+	LOG_DIFF_ADDED   LogDiffType = iota // LOG_DIFF_ADDED is a log present only in the second slice
+	LOG_DIFF_REMOVED                    // LOG_DIFF_REMOVED is a log present only in the first slice
+	LOG_DIFF_CHANGED                    // LOG_DIFF_CHANGED is a log present in both slices, but with different details
+)
+
+func (t LogDiffType) String() string {
+	switch t {
+	case LOG_DIFF_ADDED:
+		return "added"
+	case LOG_DIFF_REMOVED:
+		return "removed"
+	case LOG_DIFF_CHANGED:
+		return "changed"
+	default:
+		return "unknown"
+	}
+}
+
+// LogDiff is a single difference between two slices of logs. Old and A are
+// the log and its index in the first slice, New and B are the log and its
+// index in the second slice: for added and removed logs, the missing side
+// is nil with an index of -1
+type LogDiff struct {
+	Type LogDiffType
+	A, B int
+	Old  *Log
+	New  *Log
+}
+
+// DiffLogs compares two slices of logs (for example of two different runs)
+// aligning them by message, ignoring dates and IDs, and returns the logs
+// added, removed and changed (same message but different level or extra).
+// It's the same as DiffLogsBy(a, b, DIFF_BY_MESSAGE)
+func DiffLogs(a, b []Log) []LogDiff {
+	return DiffLogsBy(a, b, DIFF_BY_MESSAGE)
+}
+
+// DiffLogsBy is like DiffLogs but the logs are aligned based on the given
+// mode. The messages are compared without the terminal colors. The comparison
+// uses memory proportional to len(a) * len(b) (excluding the common head
+// and tail), so it's meant for windows of logs, not entire histories
+func DiffLogsBy(a, b []Log, mode DiffMode) []LogDiff {
+	key := func(l Log) string {
+		if mode == DIFF_BY_MESSAGE_AND_LEVEL {
+			return l.Level().String() + "\x00" + l.Message()
+		}
+		return l.Message()
+	}
+
+	keysA := make([]string, len(a))
+	for i, l := range a {
+		keysA[i] = key(l)
+	}
+	keysB := make([]string, len(b))
+	for i, l := range b {
+		keysB[i] = key(l)
+	}
+
+	var head int
+	for head < len(a) && head < len(b) && keysA[head] == keysB[head] {
+		head++
+	}
+	var tail int
+	for tail < len(a)-head && tail < len(b)-head && keysA[len(a)-1-tail] == keysB[len(b)-1-tail] {
+		tail++
+	}
+
+	var diffs []LogDiff
+	matched := func(i, j int) {
+		if a[i].Level() != b[j].Level() || a[i].Extra() != b[j].Extra() {
+			diffs = append(diffs, LogDiff{
+				Type: LOG_DIFF_CHANGED,
+				A: i, B: j,
+				Old: &a[i], New: &b[j],
+			})
+		}
+	}
+
+	for i := 0; i < head; i++ {
+		matched(i, i)
+	}
+
+	// Longest common subsequence of the remaining middle parts
+	n, m := len(a)-head-tail, len(b)-head-tail
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if keysA[head+i] == keysB[head+j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && keysA[head+i] == keysB[head+j]:
+			matched(head+i, head+j)
+			i++
+			j++
+		case j < m && (i == n || lcs[i][j+1] >= lcs[i+1][j]):
+			diffs = append(diffs, LogDiff{
+				Type: LOG_DIFF_ADDED,
+				A: -1, B: head + j,
+				New: &b[head+j],
+			})
+			j++
+		default:
+			diffs = append(diffs, LogDiff{
+				Type: LOG_DIFF_REMOVED,
+				A: head + i, B: -1,
+				Old: &a[head+i],
+			})
+			i++
+		}
+	}
+
+	for k := 0; k < tail; k++ {
+		matched(len(a)-tail+k, len(b)-tail+k)
+	}
+
+	return diffs
+}
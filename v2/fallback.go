@@ -0,0 +1,72 @@
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FallbackRateLimit is the maximum number of logs sent to the fallback
+// Logger every second (see SetFallback): the others are dropped, and
+// how many were dropped is reported to the fallback Logger afterwards
+var FallbackRateLimit = 100
+
+// fallback receives the logs that the storage of a Logger failed to store
+type fallback struct {
+	fm          sync.Mutex
+	l           Logger
+	windowStart time.Time
+	sent        int
+	dropped     int
+}
+
+// SetFallback sets the Logger that receives the logs that can't be
+// stored, for example because the disk of a HugeLogger is full, so that
+// they are not lost: the first failure of every second is reported to it
+// with an ERROR log, followed by the logs themselves, up to
+// FallbackRateLimit per second. The fallback should be a simple and
+// reliable Logger, like an in-memory Logger writing to os.Stderr, and
+// it must not be the Logger itself. A nil Logger removes the fallback:
+// then the logs that can't be stored are only written to the output
+func (f *fallback) SetFallback(l Logger) {
+	f.fm.Lock()
+	defer f.fm.Unlock()
+
+	f.l = l
+}
+
+// sendToFallback sends the log that the storage failed to store with
+// the given error to the fallback Logger, respecting FallbackRateLimit.
+// It reports false if there is no fallback Logger
+func (f *fallback) sendToFallback(log Log, err error) bool {
+	f.fm.Lock()
+	fl := f.l
+	if fl == nil {
+		f.fm.Unlock()
+		return false
+	}
+
+	var first bool
+	var dropped int
+	if now := time.Now(); now.Sub(f.windowStart) >= time.Second {
+		first, dropped = true, f.dropped
+		f.windowStart, f.sent, f.dropped = now, 0, 0
+	}
+
+	if f.sent >= FallbackRateLimit {
+		f.dropped ++
+		f.fm.Unlock()
+		return true
+	}
+	f.sent ++
+	f.fm.Unlock()
+
+	if dropped > 0 {
+		fl.newLog(newInternalLog(LOG_LEVEL_WARNING, fmt.Sprintf("%d logs were not sent to the fallback Logger due to the rate limit", dropped), ""), true)
+	}
+	if first {
+		fl.newLog(newInternalLog(LOG_LEVEL_ERROR, "Logger storage failed, sending the logs to the fallback Logger", err.Error()), true)
+	}
+	fl.newLog(log.copy(), true)
+	return true
+}
@@ -0,0 +1,76 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strings"
+)
+
+// FatalMode decides what Fatal and Fatalf do after
+// creating the FATAL log (see SetFatalMode)
+type FatalMode int
+
+const (
+	// FATAL_EXIT terminates the program with os.Exit(1); this is the
+	// default and it keeps the exits clean in production
+	FATAL_EXIT FatalMode = iota
+	// FATAL_PANIC panics with the message of the log, so that the
+	// goroutine dump shows where things went wrong. The log has the
+	// stack trace of the call in its extra. It's meant for development
+	FATAL_PANIC
+	// FATAL_CONTINUE only creates the log and returns: the caller
+	// is responsible for handling the fatal condition
+	FATAL_CONTINUE
+)
+
+func (mode FatalMode) String() string {
+	switch mode {
+	case FATAL_EXIT:
+		return "exit"
+	case FATAL_PANIC:
+		return "panic"
+	case FATAL_CONTINUE:
+		return "continue"
+	default:
+		return "???"
+	}
+}
+
+func (mode FatalMode) MarshalText() ([]byte, error) {
+	return []byte(mode.String()), nil
+}
+
+// fatal creates the FATAL log with the given message, like Print,
+// and then exits, panics or returns following the mode. Before exiting
+// or panicking, the Logger is flushed, so that the logs still in the
+// asynchronous output or in the HugeLogger buffers are not lost
+func fatal(l Logger, mode FatalMode, str string) {
+	message, extra, _ := strings.Cut(str, "\n")
+
+	if mode == FATAL_PANIC {
+		stack := string(debug.Stack())
+		if extra != "" {
+			extra += "\n\n" + stack
+		} else {
+			extra = stack
+		}
+	}
+
+	l.AddLog(LOG_LEVEL_FATAL, message, extra, true)
+	if mode != FATAL_CONTINUE {
+		l.Flush()
+	}
+
+	switch mode {
+	case FATAL_EXIT:
+		os.Exit(1)
+	case FATAL_PANIC:
+		panic(message)
+	}
+}
+
+// fatalArgs joins the arguments like Print does
+func fatalArgs(a ...any) string {
+	return strings.TrimSuffix(fmt.Sprintln(a...), "\n")
+}
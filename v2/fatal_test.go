@@ -0,0 +1,68 @@
+package logger
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// delayedWriter waits before every write to w
+type delayedWriter struct {
+	w     io.Writer
+	delay time.Duration
+}
+
+func (w delayedWriter) Write(p []byte) (int, error) {
+	time.Sleep(w.delay)
+	return w.w.Write(p)
+}
+
+func TestFatalPanicFlushesAsyncOutput(t *testing.T) {
+	w := &slowWriter{ delay: 20 * time.Millisecond }
+	l := NewLogger(w)
+	l.SetFormatter(messageFormatter{})
+	l.SetFatalMode(FATAL_PANIC)
+	l.EnableAsyncOutput(16)
+	defer l.DisableAsyncOutput()
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Fatal did not panic")
+			}
+		}()
+		l.Print(LOG_LEVEL_INFO, "before")
+		l.Fatal("boom")
+	}()
+
+	if got, want := w.String(), "before\nboom\n"; got != want {
+		t.Errorf("output when panicking %q, want %q", got, want)
+	}
+}
+
+func TestFatalExitFlushesAsyncOutput(t *testing.T) {
+	if os.Getenv("LOGGER_FATAL_CHILD") == "1" {
+		l := NewLogger(delayedWriter{ w: os.Stdout, delay: 20 * time.Millisecond })
+		l.SetFormatter(messageFormatter{})
+		l.EnableAsyncOutput(16)
+		l.Print(LOG_LEVEL_INFO, "before")
+		l.Fatal("boom")
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFatalExitFlushesAsyncOutput$")
+	cmd.Env = append(os.Environ(), "LOGGER_FATAL_CHILD=1")
+	out, err := cmd.Output()
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		t.Fatalf("the program ended with %v, want exit status 1", err)
+	}
+	if !strings.HasPrefix(string(out), "before\nboom\n") {
+		t.Errorf("output when exiting %q, want the INFO and the FATAL logs", out)
+	}
+}
@@ -0,0 +1,102 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// missingFields returns the keys in required that are not among the fields
+// of the log. Logs generated internally by the Logger are never checked
+func missingFields(log Log, required []string) []string {
+	if len(required) == 0 || log.l.internal {
+		return nil
+	}
+
+	var missing []string
+	for _, key := range required {
+		if _, ok := log.l.fields[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
+
+// missingFieldsWarning creates the diagnostic log emitted when
+// a log is missing some required fields
+func missingFieldsWarning(log Log, missing []string) Log {
+	return newInternalLog(
+		LOG_LEVEL_WARNING,
+		fmt.Sprintf("log is missing the required fields: %s", strings.Join(missing, ", ")),
+		log.String(),
+	)
+}
+
+// errorChain returns every layer of err, obtained with errors.Unwrap,
+// as a list of entries with the type and the message of the layer
+func errorChain(err error) []map[string]any {
+	var chain []map[string]any
+	for ; err != nil; err = errors.Unwrap(err) {
+		chain = append(chain, map[string]any{
+			"type":    fmt.Sprintf("%T", err),
+			"message": err.Error(),
+		})
+	}
+	return chain
+}
+
+func addLogFields(l Logger, level LogLevel, message string, fields map[string]any, writeOutput bool, caller bool) int {
+	log := createLog(level, message, "", caller)
+
+	log.l.fields = make(map[string]any, len(fields))
+	for key, value := range fields {
+		log.l.fields[key] = value
+	}
+
+	return l.newLog(log, writeOutput)
+}
+
+// renderFields renders the fields one "key=value" per line, sorted by
+// key, with the values encoded in JSON (or formatted with %v if that fails)
+func renderFields(fields map[string]any) string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	lines := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value, err := json.Marshal(fields[key])
+		if err != nil {
+			value = []byte(fmt.Sprintf("%v", fields[key]))
+		}
+		lines = append(lines, key + "=" + string(value))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func addError(l Logger, level LogLevel, err error, caller bool) {
+	if err == nil || !l.Enabled(level) {
+		return
+	}
+
+	chain := errorChain(err)
+	lines := make([]string, 0, len(chain))
+	for i, layer := range chain {
+		lines = append(lines, fmt.Sprintf("%s%s: %s", strings.Repeat("  ", i), layer["type"], layer["message"]))
+	}
+
+	log := createLog(level, err.Error(), strings.Join(lines, "\n"), caller)
+
+	fields := make(map[string]any, len(log.l.fields) + 1)
+	for key, value := range log.l.fields {
+		fields[key] = value
+	}
+	fields["errors"] = chain
+	log.l.fields = fields
+
+	l.newLog(log, true)
+}
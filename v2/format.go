@@ -0,0 +1,90 @@
+package logger
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formatter decides how a log is written to the output of a Logger
+// (see SetFormatter). When colored is true the output is a terminal
+// and the log should be colored. The extra and the fields of the log have
+// already been removed if the Logger has the extras disabled
+type Formatter interface {
+	Format(l Log, colored bool) string
+}
+
+// DefaultFormatter writes the logs in the usual
+// "[date] - Level: message" layout, with the extra on
+// indented new lines; a log with fields but no extra has its fields
+// written in place of the extra. It is the Formatter used by default
+type DefaultFormatter struct{}
+
+func (DefaultFormatter) Format(l Log, colored bool) string {
+	if l.l.extra == "" && len(l.l.fields) > 0 {
+		l = withExtra(l, renderFields(l.l.fields))
+	}
+
+	switch {
+	case colored && l.l.extra != "":
+		return l.l.fullColored()
+	case colored:
+		return l.l.colored()
+	case l.l.extra != "":
+		return l.l.full()
+	default:
+		return l.l.String()
+	}
+}
+
+// withExtra returns a copy of the log with the given extra
+func withExtra(log Log, extra string) Log {
+	l := *log.l
+	l.extra = extra
+	return Log{ l: &l, tags: log.tags }
+}
+
+// LogfmtFormatter writes the logs as logfmt key=value pairs, like
+//
+//	time=2006-01-02T15:04:05.999999999Z07:00 level=info msg="a message" extra=... tags="a,b,c"
+//
+// The values containing spaces, quotes, equal signs or control
+// characters are quoted. The extra and the tags are omitted when empty.
+// The logs are never colored and the terminal colors are removed
+type LogfmtFormatter struct{}
+
+func (LogfmtFormatter) Format(l Log, colored bool) string {
+	var sb strings.Builder
+
+	sb.WriteString("time=")
+	sb.WriteString(l.Date().Format(time.RFC3339Nano))
+	sb.WriteString(" level=")
+	sb.WriteString(logfmtValue(strings.ToLower(strings.TrimSpace(l.Level().String()))))
+	sb.WriteString(" msg=")
+	sb.WriteString(logfmtValue(l.Message()))
+
+	if extra := l.Extra(); extra != "" {
+		sb.WriteString(" extra=")
+		sb.WriteString(logfmtValue(extra))
+	}
+	if tags := l.Tags(); len(tags) > 0 {
+		sb.WriteString(" tags=")
+		sb.WriteString(strconv.Quote(strings.Join(tags, ",")))
+	}
+
+	return sb.String()
+}
+
+// logfmtValue returns s quoted if it cannot be written
+// as a bare logfmt value
+func logfmtValue(s string) string {
+	if s == "" {
+		return `""`
+	}
+	if strings.ContainsAny(s, " =\"\\") || strings.IndexFunc(s, func(r rune) bool {
+		return r < ' ' || r == 0x7f
+	}) >= 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -1,3 +1,3 @@
 module github.com/nixpare/logger/v2
 
-go 1.20
+go 1.21
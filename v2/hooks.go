@@ -0,0 +1,55 @@
+package logger
+
+import "sync"
+
+// hooks holds the callbacks registered with AddHook
+type hooks struct {
+	hm   sync.RWMutex
+	fns  []hook
+	next int
+}
+
+type hook struct {
+	id int
+	fn func(Log)
+}
+
+// AddHook registers a callback that is called synchronously for every
+// new log stored by the Logger (including the ones created by its clones),
+// after it is stored and before it is written to the output, with all its
+// tags. The hooks are called in the order they were added, without
+// holding any lock of the Logger, but they should not create logs in the
+// same Logger, since that would call them again. It returns an id that
+// can be used to remove the hook with RemoveHook
+func (h *hooks) AddHook(fn func(Log)) int {
+	h.hm.Lock()
+	defer h.hm.Unlock()
+
+	h.next ++
+	h.fns = append(h.fns, hook{ id: h.next, fn: fn })
+	return h.next
+}
+
+// RemoveHook removes the hook with the given id, returned by AddHook.
+// It does nothing if there is no such hook
+func (h *hooks) RemoveHook(id int) {
+	h.hm.Lock()
+	defer h.hm.Unlock()
+
+	for i, hook := range h.fns {
+		if hook.id == id {
+			h.fns = append(h.fns[:i:i], h.fns[i+1:]...)
+			return
+		}
+	}
+}
+
+func (h *hooks) runHooks(log Log) {
+	h.hm.RLock()
+	fns := h.fns
+	h.hm.RUnlock()
+
+	for _, hook := range fns {
+		hook.fn(log)
+	}
+}
@@ -0,0 +1,38 @@
+package logger
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestHookCountsFatal(t *testing.T) {
+	huge, _ := newTestHugeLogger(t)
+
+	for name, l := range map[string]Logger{ "memory": NewLogger(nil), "huge": huge } {
+		var fatals atomic.Int32
+		var tagged bool
+		id := l.AddHook(func(log Log) {
+			if log.Level() == LOG_LEVEL_FATAL {
+				fatals.Add(1)
+				tagged = tagged || log.Match("worker")
+			}
+		})
+
+		l.AddLog(LOG_LEVEL_FATAL, "first", "", false)
+		l.AddLog(LOG_LEVEL_ERROR, "not fatal", "", false)
+		l.Clone(nil, "worker").AddLog(LOG_LEVEL_FATAL, "from the clone", "", false)
+
+		if n := fatals.Load(); n != 2 {
+			t.Errorf("%s: the hook counted %d fatal logs, want 2", name, n)
+		}
+		if !tagged {
+			t.Errorf("%s: the hook did not receive the tags of the clone", name)
+		}
+
+		l.RemoveHook(id)
+		l.AddLog(LOG_LEVEL_FATAL, "after the removal", "", false)
+		if n := fatals.Load(); n != 2 {
+			t.Errorf("%s: the hook was called after RemoveHook", name)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Package httplog logs the requests served by a net/http server
+// into a Logger (see Middleware)
+package httplog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nixpare/logger/v2"
+)
+
+// RequestIDHeader is the header that carries the request id: when the
+// request already has it, its value is used instead of a new id, and
+// it is always set on the response
+var RequestIDHeader = "X-Request-Id"
+
+type ctxKey struct{}
+
+// Middleware returns a middleware that logs every request served by the
+// handler with its method, path, status and duration, once the handler
+// returns. Every request is given a request id, which is injected in the
+// request context together with a clone of l tagged with that id (see
+// logger.NewContext), so that the handler can log with RequestID and
+// logger.FromContext. The requests are logged at INFO, or at WARNING
+// for a status >= 400 and at ERROR for a status >= 500
+func Middleware(l logger.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			id := r.Header.Get(RequestIDHeader)
+			if id == "" {
+				id = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, id)
+
+			reqLogger := l.Clone(nil, id)
+			ctx := context.WithValue(r.Context(), ctxKey{}, id)
+			ctx = logger.NewContext(ctx, reqLogger)
+
+			rw := &responseWriter{ ResponseWriter: w }
+			next.ServeHTTP(rw, r.WithContext(ctx))
+
+			status := rw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			reqLogger.AddLog(
+				statusLevel(status),
+				fmt.Sprintf("%s %s %d (%v)", r.Method, r.URL.Path, status, time.Since(start)),
+				fmt.Sprintf("bytes=%d remote=%s", rw.bytes, r.RemoteAddr),
+				true,
+			)
+		})
+	}
+}
+
+// RequestID returns the id given to the request by
+// Middleware, or an empty string if there is none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// FromContext returns the Logger tagged with the request id injected
+// by Middleware, or nil if there is none. Middleware stores it with
+// logger.NewContext, so logger.FromContext returns it as well, but
+// falls back to logger.DefaultLogger outside of Middleware
+func FromContext(ctx context.Context) logger.Logger {
+	if RequestID(ctx) == "" {
+		return nil
+	}
+	return logger.FromContext(ctx)
+}
+
+func statusLevel(status int) logger.LogLevel {
+	switch {
+	case status >= 500:
+		return logger.LOG_LEVEL_ERROR
+	case status >= 400:
+		return logger.LOG_LEVEL_WARNING
+	default:
+		return logger.LOG_LEVEL_INFO
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// responseWriter records the status and the
+// number of bytes written by the handler
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += n
+	return n, err
+}
+
+// Unwrap returns the original ResponseWriter,
+// so that http.ResponseController can reach it
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,37 @@
+package httplog
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nixpare/logger/v2"
+)
+
+func TestFromContext(t *testing.T) {
+	if l := FromContext(context.Background()); l != nil {
+		t.Errorf("FromContext outside of Middleware = %v, want nil", l)
+	}
+
+	l := logger.NewLogger(nil)
+	var handlerLogger, coreLogger logger.Logger
+	h := Middleware(l)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlerLogger = FromContext(r.Context())
+		coreLogger = logger.FromContext(r.Context())
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/path", nil)
+	req.Header.Set(RequestIDHeader, "abc")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if handlerLogger == nil || handlerLogger != coreLogger {
+		t.Fatalf("FromContext = %v, logger.FromContext = %v, want the same request Logger", handlerLogger, coreLogger)
+	}
+
+	logs := l.GetLastNLogs(1)
+	if len(logs) != 1 || !logs[0].Match("abc") || logs[0].Level() != logger.LOG_LEVEL_ERROR {
+		t.Errorf("request log = %v, want an ERROR tagged with the request id", logs)
+	}
+}
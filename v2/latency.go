@@ -0,0 +1,77 @@
+package logger
+
+import (
+	"sync"
+	"time"
+)
+
+// WriteLatencyWeight is the weight of the last write in the average
+// returned by AvgWriteLatency, between 0 and 1: the higher, the faster
+// the average follows the changes
+var WriteLatencyWeight = 0.1
+
+// writeLatency holds the exponentially weighted moving average
+// of the time taken to write the logs of each level to the output
+type writeLatency struct {
+	m   sync.Mutex
+	avg map[LogLevel]time.Duration
+}
+
+func (wl *writeLatency) observe(level LogLevel, d time.Duration) {
+	wl.m.Lock()
+	defer wl.m.Unlock()
+
+	avg, ok := wl.avg[level]
+	if !ok {
+		wl.avg[level] = d
+		return
+	}
+	wl.avg[level] = avg + time.Duration(WriteLatencyWeight * float64(d - avg))
+}
+
+// EnableWriteLatency starts measuring how long it takes to write each log
+// to the output, including the formatting, to find slow outputs (see
+// AvgWriteLatency). With the asynchronous output the time is measured
+// on the goroutine writing the logs. Clones do not inherit it
+func (o *output) EnableWriteLatency() {
+	o.latency.CompareAndSwap(nil, &writeLatency{ avg: make(map[LogLevel]time.Duration) })
+}
+
+// DisableWriteLatency stops measuring the write latency and
+// discards the averages
+func (o *output) DisableWriteLatency() {
+	o.latency.Store(nil)
+}
+
+// AvgWriteLatency returns the average time taken to write a log with
+// the given level to the output, giving more weight to the last writes
+// (see WriteLatencyWeight), or zero if no log with that level was written
+// since EnableWriteLatency
+func (o *output) AvgWriteLatency(level LogLevel) time.Duration {
+	wl := o.latency.Load()
+	if wl == nil {
+		return 0
+	}
+
+	wl.m.Lock()
+	defer wl.m.Unlock()
+	return wl.avg[level]
+}
+
+// writeLatencies returns the average write latency of
+// every level, by level name, or nil if not enabled
+func (o *output) writeLatencies() map[string]time.Duration {
+	wl := o.latency.Load()
+	if wl == nil {
+		return nil
+	}
+
+	wl.m.Lock()
+	defer wl.m.Unlock()
+
+	res := make(map[string]time.Duration, len(wl.avg))
+	for level, avg := range wl.avg {
+		res[level.Name()] = avg
+	}
+	return res
+}
@@ -0,0 +1,80 @@
+package logger
+
+import (
+	"io"
+	"strings"
+)
+
+// LevelPrefixes maps the level names recognized by LevelParsingWriter,
+// in upper case, to their LogLevel. It can be modified, before creating
+// the writers, to recognize the names used by other libraries
+var LevelPrefixes = map[string]LogLevel{
+	"DEBUG":   LOG_LEVEL_DEBUG,
+	"INFO":    LOG_LEVEL_INFO,
+	"WARN":    LOG_LEVEL_WARNING,
+	"WARNING": LOG_LEVEL_WARNING,
+	"ERR":     LOG_LEVEL_ERROR,
+	"ERROR":   LOG_LEVEL_ERROR,
+	"FATAL":   LOG_LEVEL_FATAL,
+}
+
+// levelParsingWriter is the io.Writer returned by LevelParsingWriter
+type levelParsingWriter struct {
+	l Logger
+	partialLine
+}
+
+// LevelParsingWriter returns an io.Writer that creates a log in l for each
+// line written, like Logger.Write, but with the level taken from the start
+// of the line, so that a third-party library writing "[WARN] disk low"
+// creates a warning with the message "disk low". The level can be written
+// as "[NAME]", "NAME:" or "level=name" (with the name optionally quoted),
+// where the names are the keys of LevelPrefixes, matched ignoring the
+// case; the recognized prefix is removed from the message. Without a
+// recognized prefix the log has LOG_LEVEL_BLANK, like with Logger.Write.
+// A line split across many writes is logged once completed
+func LevelParsingWriter(l Logger) io.Writer {
+	return &levelParsingWriter{ l: l }
+}
+
+func (w *levelParsingWriter) Write(p []byte) (n int, err error) {
+	for _, line := range w.lines(p) {
+		level, message := parseLevelPrefix(line)
+		w.l.Print(level, message)
+	}
+	return len(p), nil
+}
+
+// parseLevelPrefix returns the level written at the start of s
+// (see LevelParsingWriter) and the rest of s, or LOG_LEVEL_BLANK
+// and s itself if there is no level
+func parseLevelPrefix(s string) (LogLevel, string) {
+	text := strings.TrimLeft(s, " \t")
+
+	var name, rest string
+	switch {
+	case strings.HasPrefix(text, "["):
+		end := strings.IndexByte(text, ']')
+		if end < 0 {
+			return LOG_LEVEL_BLANK, s
+		}
+		name, rest = text[1:end], text[end+1:]
+
+	case len(text) >= 6 && strings.EqualFold(text[:6], "level="):
+		name, rest, _ = strings.Cut(text[6:], " ")
+		name = strings.Trim(name, `"`)
+
+	default:
+		end := strings.IndexAny(text, ": \t\n")
+		if end < 0 || text[end] != ':' {
+			return LOG_LEVEL_BLANK, s
+		}
+		name, rest = text[:end], text[end+1:]
+	}
+
+	level, ok := LevelPrefixes[strings.ToUpper(strings.TrimSpace(name))]
+	if !ok {
+		return LOG_LEVEL_BLANK, s
+	}
+	return level, strings.TrimLeft(rest, " \t")
+}
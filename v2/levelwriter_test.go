@@ -0,0 +1,49 @@
+package logger
+
+import (
+	stdlog "log"
+	"testing"
+)
+
+func TestLevelParsingWriter(t *testing.T) {
+	l := NewLogger(nil)
+	w := LevelParsingWriter(l)
+
+	w.Write([]byte("[WARN] disk low\nERROR: disk full\nlevel=\"debug\" retrying\n"))
+	w.Write([]byte("plain "))
+	w.Write([]byte("text\n"))
+
+	want := []struct {
+		level   LogLevel
+		message string
+	}{
+		{ LOG_LEVEL_WARNING, "disk low" },
+		{ LOG_LEVEL_ERROR, "disk full" },
+		{ LOG_LEVEL_DEBUG, "retrying" },
+		{ LOG_LEVEL_BLANK, "plain text" },
+	}
+
+	logs := l.GetLogs(0, l.NLogs())
+	if len(logs) != len(want) {
+		t.Fatalf("got %d logs %q, want %d", len(logs), messages(logs), len(want))
+	}
+	for i, w := range want {
+		if logs[i].Level() != w.level || logs[i].Message() != w.message {
+			t.Errorf("log %d = %v %q, want %v %q", i, logs[i].Level(), logs[i].Message(), w.level, w.message)
+		}
+	}
+}
+
+func TestLevelParsingWriterStdLog(t *testing.T) {
+	l := NewLogger(nil)
+	std := stdlog.New(LevelParsingWriter(l), "", 0)
+	std.Print("[WARN] disk low")
+
+	if l.NLogs() != 1 {
+		t.Fatalf("got %d logs, want 1", l.NLogs())
+	}
+	got := l.GetLog(0)
+	if got.Level() != LOG_LEVEL_WARNING || got.Message() != "disk low" {
+		t.Errorf("log = %v %q, want a warning with \"disk low\"", got.Level(), got.Message())
+	}
+}
@@ -1,17 +1,145 @@
 package logger
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
-	"math/rand"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 var (
 	TimeFormat = "2006-01-02 15:04:05.00" // TimeFormat defines which timestamp to use with the logs. It can be modified.
 )
 
+// timeFunc, if set, replaces TimeFormat when rendering the date of the logs
+var timeFunc func(t time.Time) string
+
+// CustomTimeFunc sets a function used to render the date of every log
+// in place of TimeFormat, for anything a layout string can't express
+// (see ISOWeekTime and ISOWeekDateTime). Passing nil restores TimeFormat
+func CustomTimeFunc(f func(t time.Time) string) {
+	timeFunc = f
+}
+
+// ISOWeekTime renders the date as the ISO-8601 year and week, for
+// example 2024-W23. It can be used with CustomTimeFunc
+func ISOWeekTime(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%04d-W%02d", year, week)
+}
+
+// ISOWeekDateTime renders the date as the ISO-8601 week date followed by
+// the time, for example 2024-W23-5 15:04:05.00 (where 5 is the day of the
+// week, starting from Monday). It can be used with CustomTimeFunc
+func ISOWeekDateTime(t time.Time) string {
+	weekday := int(t.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	return fmt.Sprintf("%s-%d %s", ISOWeekTime(t), weekday, t.Format("15:04:05.00"))
+}
+
+// timeLocation, if set, is the location of the dates of the new logs
+// and the one used to render the dates (see SetTimeLocation)
+var timeLocation atomic.Pointer[time.Location]
+
+// SetTimeLocation sets the location used for the date of every new log and
+// to render the dates of all the logs, including the ones created before
+// or read back from a HugeLogger, for example when the logs are collected
+// by a system expecting UTC (see UseUTC). The date of a log keeps its zone
+// in the JSON encoding, so it is preserved when stored and read back.
+// Passing nil restores the default: the new logs use the local time
+// and every date is rendered in its own location
+func SetTimeLocation(loc *time.Location) {
+	timeLocation.Store(loc)
+}
+
+// UseUTC makes the logs use UTC for their dates (see SetTimeLocation)
+func UseUTC() {
+	SetTimeLocation(time.UTC)
+}
+
+// logTime returns the current time in the location set with SetTimeLocation
+func logTime() time.Time {
+	t := time.Now()
+	if loc := timeLocation.Load(); loc != nil {
+		return t.In(loc)
+	}
+	return t
+}
+
+// FormatRelativeTime renders how long before now t is, like "12ms ago",
+// "3s ago", "5m ago", "2h ago" or "4d ago", truncating to the largest unit
+// (see SetRelativeTime). A t less than a millisecond before now, or after
+// it, is "just now"
+func FormatRelativeTime(t time.Time, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < time.Millisecond:
+		return "just now"
+	case d < time.Second:
+		return fmt.Sprintf("%dms ago", d.Milliseconds())
+	case d < time.Minute:
+		return fmt.Sprintf("%ds ago", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24 * time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()) / 24)
+	}
+}
+
+// formatDate renders the date of a log (see TimeFormat, CustomTimeFunc
+// and SetTimeLocation)
+func formatDate(t time.Time) string {
+	if loc := timeLocation.Load(); loc != nil {
+		t = t.In(loc)
+	}
+	if timeFunc != nil {
+		return timeFunc(t)
+	}
+	return t.Format(TimeFormat)
+}
+
+// extraFieldParser, if set, is used to populate the fields
+// of every new log from its extra
+var extraFieldParser func(extra string) map[string]any
+
+// SetExtraFieldParser sets a function that is used, when a new log is created,
+// to parse its extra into structured fields (see Log.Fields); the raw extra
+// is kept untouched and is still used for display. This is useful to migrate
+// from free-form extras to structured fields: see ParseKeyValueExtra for an
+// example of parser. Passing nil disables the parsing
+func SetExtraFieldParser(parser func(extra string) map[string]any) {
+	extraFieldParser = parser
+}
+
+// ParseKeyValueExtra is a parser for SetExtraFieldParser that splits
+// the extra in lines and each line in the form "key: value" into a field.
+// Lines that are not in this form are ignored
+func ParseKeyValueExtra(extra string) map[string]any {
+	fields := make(map[string]any)
+	for _, line := range strings.Split(extra, "\n") {
+		key, value, found := strings.Cut(line, ":")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			continue
+		}
+
+		fields[key] = strings.TrimSpace(value)
+	}
+	return fields
+}
+
 // LogLevel defines the severity of a Log. See the constants
 type LogLevel int
 
@@ -24,6 +152,10 @@ const (
 	LOG_LEVEL_FATAL
 )
 
+// SEVERITY_UNKNOWN is the severity of the levels that are neither built-in
+// nor registered with RegisterLevel (see LogLevel.Severity)
+const SEVERITY_UNKNOWN = math.MaxInt32
+
 func (level LogLevel) String() string {
 	switch level {
 	case LOG_LEVEL_BLANK:
@@ -38,16 +170,83 @@ func (level LogLevel) String() string {
 		return "  Error"
 	case LOG_LEVEL_FATAL:
 		return "  Fatal"
-	default:
-		return "  ???  "
 	}
+
+	if custom, ok := lookupLevel(level); ok {
+		return custom.label
+	}
+	return "  ???  "
+}
+
+// Severity returns the rank of the level, from LOG_LEVEL_BLANK (the lowest)
+// to LOG_LEVEL_FATAL. Unlike the constants order, LOG_LEVEL_DEBUG is ranked
+// below LOG_LEVEL_INFO. The levels created with RegisterLevel are ranked
+// above LOG_LEVEL_FATAL in the order they were registered, while unknown
+// levels are ranked above every other level, with SEVERITY_UNKNOWN
+func (level LogLevel) Severity() int {
+	switch level {
+	case LOG_LEVEL_BLANK:
+		return 0
+	case LOG_LEVEL_DEBUG:
+		return 1
+	case LOG_LEVEL_INFO:
+		return 2
+	case LOG_LEVEL_WARNING:
+		return 3
+	case LOG_LEVEL_ERROR:
+		return 4
+	case LOG_LEVEL_FATAL:
+		return 5
+	}
+
+	if _, ok := lookupLevel(level); ok {
+		return int(level)
+	}
+	return SEVERITY_UNKNOWN
+}
+
+// AtLeast reports whether level is at least as severe as min
+func (level LogLevel) AtLeast(min LogLevel) bool {
+	return level.Severity() >= min.Severity()
+}
+
+// Name returns the lowercase name of the level, like "warning", used
+// in its JSON representation, or the name given to RegisterLevel
+func (level LogLevel) Name() string {
+	if custom, ok := lookupLevel(level); ok {
+		return custom.name
+	}
+	return strings.TrimSpace(strings.ToLower(level.String()))
+}
+
+// jsonNumericLevel is set with SetJSONNumericLevel
+var jsonNumericLevel atomic.Bool
+
+// SetJSONNumericLevel sets whether the levels are encoded in JSON as their
+// severity rank (see LogLevel.Severity), like "level": 3 for a warning,
+// for the systems that sort or filter by numeric severity, instead of
+// their name, like "level": "warning", which is the default. The levels
+// created with RegisterLevel and the unknown ones are always encoded by
+// name, since their rank depends on the registration order. Decoding
+// accepts both forms regardless of this setting
+func SetJSONNumericLevel(numeric bool) {
+	jsonNumericLevel.Store(numeric)
 }
 
 func (level LogLevel) MarshalJSON() ([]byte, error) {
-	return json.Marshal(strings.TrimSpace(strings.ToLower(level.String())))
+	if jsonNumericLevel.Load() && level >= LOG_LEVEL_BLANK && level <= LOG_LEVEL_FATAL {
+		return json.Marshal(level.Severity())
+	}
+	return json.Marshal(level.Name())
 }
 
 func (level *LogLevel) UnmarshalJSON(b []byte) error {
+	var severity int
+	if json.Unmarshal(b, &severity) == nil {
+		*level = levelFromSeverity(severity)
+		return nil
+	}
+
 	var s string
 	if err := json.Unmarshal(b, &s); err != nil {
 		return err
@@ -68,17 +267,116 @@ func (level *LogLevel) UnmarshalJSON(b []byte) error {
 		*level = LOG_LEVEL_FATAL
 	default:
 		*level = -1
+
+		customLevels.m.RLock()
+		if registered, ok := customLevels.byName[s]; ok {
+			*level = registered
+		}
+		customLevels.m.RUnlock()
 	}
 
 	return nil
 }
 
+// levelFromSeverity returns the level with the given severity
+// rank (see LogLevel.Severity), or -1 if there is none
+func levelFromSeverity(severity int) LogLevel {
+	switch severity {
+	case 0:
+		return LOG_LEVEL_BLANK
+	case 1:
+		return LOG_LEVEL_DEBUG
+	case 2:
+		return LOG_LEVEL_INFO
+	case 3:
+		return LOG_LEVEL_WARNING
+	case 4:
+		return LOG_LEVEL_ERROR
+	case 5:
+		return LOG_LEVEL_FATAL
+	}
+
+	if _, ok := lookupLevel(LogLevel(severity)); ok {
+		return LogLevel(severity)
+	}
+	return -1
+}
+
+// customLevel is a level created with RegisterLevel
+type customLevel struct {
+	name  string
+	label string
+	color string
+}
+
+// customLevels holds the levels created with RegisterLevel
+var customLevels = struct {
+	m      sync.RWMutex
+	levels map[LogLevel]customLevel
+	byName map[string]LogLevel
+}{}
+
+// RegisterLevel creates a new LogLevel for a domain concept, like
+// "audit" or "security", above the built-in levels. The name is used
+// in the JSON representation of the level (lowercased), the label is
+// returned by String and the color is used when the logs are written
+// to a terminal. Registering a name again returns the level already
+// created for it, without changing it. It's safe for concurrent use
+func RegisterLevel(name string, label string, color string) LogLevel {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	customLevels.m.Lock()
+	defer customLevels.m.Unlock()
+
+	if level, ok := customLevels.byName[name]; ok {
+		return level
+	}
+
+	if customLevels.levels == nil {
+		customLevels.levels = make(map[LogLevel]customLevel)
+		customLevels.byName = make(map[string]LogLevel)
+	}
+
+	level := LOG_LEVEL_FATAL + 1 + LogLevel(len(customLevels.levels))
+	customLevels.levels[level] = customLevel{ name: name, label: label, color: color }
+	customLevels.byName[name] = level
+
+	return level
+}
+
+// lookupLevel returns the level created with RegisterLevel, if any
+func lookupLevel(level LogLevel) (customLevel, bool) {
+	if level <= LOG_LEVEL_FATAL {
+		return customLevel{}, false
+	}
+
+	customLevels.m.RLock()
+	defer customLevels.m.RUnlock()
+
+	custom, ok := customLevels.levels[level]
+	return custom, ok
+}
+
 type log struct {
-	id      string
-	level   LogLevel  // Level is the Log severity (INFO - DEBUG - WARNING - ERROR - FATAL)
-	date    time.Time // Date is the timestamp of the log creation
-	message string    // Message is the main message that should summarize the event
-	extra   string    // Extra should hold any extra information provided for deeper understanding of the event
+	id        string
+	level     LogLevel       // Level is the Log severity (INFO - DEBUG - WARNING - ERROR - FATAL)
+	date      time.Time      // Date is the timestamp of the log creation
+	message   string         // Message is the main message that should summarize the event
+	extra     string         // Extra should hold any extra information provided for deeper understanding of the event
+	seq       int            // Seq is the storage index of the log at creation time, or -1 if not tracked
+	globalSeq uint64         // GlobalSeq is the process-wide creation sequence number of the log
+	caller    string         // Caller is the source location that created the log, if tracked
+	fields    map[string]any // Fields holds the structured data associated with the log
+	internal  bool           // Internal is true for the diagnostic logs generated by the Logger itself
+	shownDate string         // ShownDate, if set, is written in place of the date (see SetRelativeTime)
+}
+
+// renderDate returns the date as written in the text of the log
+func (l log) renderDate() string {
+	if l.shownDate != "" {
+		return l.shownDate
+	}
+	return formatDate(l.date)
 }
 
 func (l log) cleanMessage() string {
@@ -89,66 +387,261 @@ func (l log) cleanExtra() string {
 	return strings.TrimSpace(RemoveTerminalColors(l.extra))
 }
 
+// sanitizeMessage collapses the runs of whitespace of s into a single
+// space (or a single newline, if the run contains one) and removes the
+// other control characters, keeping the terminal color sequences
+func sanitizeMessage(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s))
+
+	var space rune
+	for i := 0; i < len(s); {
+		if n := escapeLen(s[i:]); n > 0 {
+			if space != 0 {
+				sb.WriteRune(space)
+				space = 0
+			}
+			sb.WriteString(s[i:i+n])
+			i += n
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+
+		switch {
+		case r == '\n':
+			space = '\n'
+		case unicode.IsSpace(r):
+			if space == 0 {
+				space = ' '
+			}
+		case unicode.IsControl(r):
+		default:
+			if space != 0 {
+				sb.WriteRune(space)
+				space = 0
+			}
+			sb.WriteRune(r)
+		}
+	}
+	if space != 0 {
+		sb.WriteRune(space)
+	}
+
+	return sb.String()
+}
+
+// globalSeq is the last sequence number given to a log (see Log.Seq)
+var globalSeq atomic.Uint64
+
+// lastLogID is the last ID given to a log
+var lastLogID atomic.Uint64
+
+// nextLogID returns a new log ID: the microseconds of t followed by
+// three digits, like 1700000000000000123. The IDs are unique, even when
+// many logs are created in the same microsecond, and they grow with
+// time, so that sorting them as strings sorts the logs by creation
+func nextLogID(t time.Time) string {
+	for {
+		last := lastLogID.Load()
+
+		id := uint64(t.UnixNano() / 1000) * 1000
+		if id <= last {
+			id = last + 1
+		}
+		if lastLogID.CompareAndSwap(last, id) {
+			return strconv.FormatUint(id, 10)
+		}
+	}
+}
+
 func newLog(level LogLevel, message string, extra string) *log {
-	t := time.Now()
+	t := logTime()
 
-	return &log{
-		id: fmt.Sprintf(
-			"%d%03d",
-			t.UnixNano() / 1000, rand.Intn(1000),
-		),
+	l := &log{
+		id: nextLogID(t),
 		level: level, date: t,
 		message: message, extra: extra,
+		seq: -1, globalSeq: globalSeq.Add(1),
+	}
+
+	if extraFieldParser != nil && extra != "" {
+		l.fields = extraFieldParser(RemoveTerminalColors(extra))
 	}
+
+	return l
+}
+
+// newInternalLog creates a diagnostic log on behalf of the Logger itself,
+// which is not subject to the checks applied to the user logs
+func newInternalLog(level LogLevel, message string, extra string) Log {
+	log := Log{ l: newLog(level, message, extra) }
+	log.l.internal = true
+	return log
 }
 
 func (l log) String() string {
 	if l.level == LOG_LEVEL_BLANK {
 		return fmt.Sprintf(
 			"[%v] - %s",
-			l.date.Format(TimeFormat),
+			l.renderDate(),
 			l.cleanMessage(),
 		)
 	}
 
 	return fmt.Sprintf(
 		"[%v] - %v: %s",
-		l.date.Format(TimeFormat),
+		l.renderDate(),
 		l.level, l.cleanMessage(),
 	)
 }
 
-func (l log) colored() string {
-	var color string
-	switch l.level {
+// levelColors holds the colors set with SetLevelColor
+var levelColors = struct {
+	m      sync.RWMutex
+	colors map[LogLevel]string
+}{}
+
+// SetLevelColor sets the color used for the given level when the logs
+// are written to a terminal, for example to use a colorblind-friendly
+// palette. Any terminal color sequence can be used, like the package
+// color constants; an empty color restores the default one
+func SetLevelColor(level LogLevel, color string) {
+	levelColors.m.Lock()
+	defer levelColors.m.Unlock()
+
+	if color == "" {
+		delete(levelColors.colors, level)
+		return
+	}
+
+	if levelColors.colors == nil {
+		levelColors.colors = make(map[LogLevel]string)
+	}
+	levelColors.colors[level] = color
+}
+
+// tagColors holds the colors set with SetTagColor
+var tagColors = struct {
+	m      sync.RWMutex
+	colors map[string]string
+}{}
+
+// SetTagColor sets the color used for the given tag when the tags are
+// shown in the output (see SetShowTags) and the logs are written to a
+// terminal, to tell apart the components of an application, like
+// SetTagColor("db", DARK_GREEN_COLOR). An empty color restores the
+// default one, which is no color
+func SetTagColor(tag string, color string) {
+	tag = strings.ToLower(tag)
+
+	tagColors.m.Lock()
+	defer tagColors.m.Unlock()
+
+	if color == "" {
+		delete(tagColors.colors, tag)
+		return
+	}
+
+	if tagColors.colors == nil {
+		tagColors.colors = make(map[string]string)
+	}
+	tagColors.colors[tag] = color
+}
+
+// tagColor returns the color set with SetTagColor for the tag, if any
+func tagColor(tag string) string {
+	tagColors.m.RLock()
+	defer tagColors.m.RUnlock()
+	return tagColors.colors[tag]
+}
+
+// renderTags returns the tags as "[a] [b]", each one
+// colored with its color, if any
+func renderTags(tags []string) string {
+	var sb strings.Builder
+	for i, tag := range tags {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		if color := tagColor(tag); color != "" {
+			sb.WriteString(color + "[" + tag + "]" + DEFAULT_COLOR)
+		} else {
+			sb.WriteString("[" + tag + "]")
+		}
+	}
+	return sb.String()
+}
+
+// levelColor returns the color of the level, either the
+// one set with SetLevelColor or the default one
+func levelColor(level LogLevel) string {
+	levelColors.m.RLock()
+	color, ok := levelColors.colors[level]
+	levelColors.m.RUnlock()
+	if ok {
+		return color
+	}
+
+	switch level {
 	case LOG_LEVEL_INFO:
-		color = BRIGHT_CYAN_COLOR
+		return BRIGHT_CYAN_COLOR
 	case LOG_LEVEL_DEBUG:
-		color = DARK_MAGENTA_COLOR
+		return DARK_MAGENTA_COLOR
 	case LOG_LEVEL_WARNING:
-		color = DARK_YELLOW_COLOR
+		return DARK_YELLOW_COLOR
 	case LOG_LEVEL_ERROR:
-		color = DARK_RED_COLOR
+		return DARK_RED_COLOR
 	case LOG_LEVEL_FATAL:
-		color = BRIGHT_RED_COLOR
+		return BRIGHT_RED_COLOR
+	}
+
+	if custom, ok := lookupLevel(level); ok {
+		return custom.color
 	}
+	return ""
+}
+
+func (l log) colored() string {
+	color := levelColor(l.level)
 
 	if l.level == LOG_LEVEL_BLANK {
 		return fmt.Sprintf(
 			"%s[%v]%s - %s%s",
-			BRIGHT_BLACK_COLOR, l.date.Format(TimeFormat), DEFAULT_COLOR,
+			BRIGHT_BLACK_COLOR, l.renderDate(), DEFAULT_COLOR,
 			l.message, DEFAULT_COLOR,
 		)
 	}
 
 	return fmt.Sprintf(
 		"%s[%v]%s - %s%v%s: %s%s",
-		BRIGHT_BLACK_COLOR, l.date.Format(TimeFormat), DEFAULT_COLOR,
+		BRIGHT_BLACK_COLOR, l.renderDate(), DEFAULT_COLOR,
 		color, l.level, DEFAULT_COLOR,
 		l.message, DEFAULT_COLOR,
 	)
 }
 
+// ExtraIndent is the number of ExtraIndentChar written before every
+// line of the extra of a log, below its message. It can be modified
+var ExtraIndent = 4
+
+// ExtraIndentChar is the character used to indent the extra of a log
+// (see ExtraIndent), like '\t' to indent it with tabs. It can be modified
+var ExtraIndentChar = ' '
+
+// indentExtra indents every line of the extra with ExtraIndent
+// ExtraIndentChar, removing the trailing whitespace like IndentString
+func indentExtra(extra string) string {
+	prefix := strings.Repeat(string(ExtraIndentChar), ExtraIndent)
+
+	lines := strings.Split(extra, "\n")
+	for i, line := range lines {
+		lines[i] = prefix + line
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), " \n")
+}
+
 // full is like String(), but appends all the extra information
 // associated with the log instance
 func (l log) full() string {
@@ -159,15 +652,15 @@ func (l log) full() string {
 	if l.level == LOG_LEVEL_BLANK {
 		return fmt.Sprintf(
 			"[%v] - %s\n%s",
-			l.date.Format(TimeFormat),
-			l.cleanMessage(), IndentString(l.cleanExtra(), 4),
+			l.renderDate(),
+			l.cleanMessage(), indentExtra(l.cleanExtra()),
 		)
 	}
 
 	return fmt.Sprintf(
 		"[%v] - %v: %s\n%s",
-		l.date.Format(TimeFormat), l.level,
-		l.cleanMessage(), IndentString(l.cleanExtra(), 4),
+		l.renderDate(), l.level,
+		l.cleanMessage(), indentExtra(l.cleanExtra()),
 	)
 }
 
@@ -178,33 +671,21 @@ func (l log) fullColored() string {
 		return l.colored()
 	}
 
-	var color string
-	switch l.level {
-	case LOG_LEVEL_INFO:
-		color = BRIGHT_CYAN_COLOR
-	case LOG_LEVEL_DEBUG:
-		color = DARK_MAGENTA_COLOR
-	case LOG_LEVEL_WARNING:
-		color = DARK_YELLOW_COLOR
-	case LOG_LEVEL_ERROR:
-		color = DARK_RED_COLOR
-	case LOG_LEVEL_FATAL:
-		color = BRIGHT_RED_COLOR
-	}
+	color := levelColor(l.level)
 
 	if l.level == LOG_LEVEL_BLANK {
 		return fmt.Sprintf(
 			"%s[%v]%s - %s\n%s%s",
-			BRIGHT_BLACK_COLOR, l.date.Format(TimeFormat), DEFAULT_COLOR,
-			l.message, IndentString(l.extra, 4), DEFAULT_COLOR,
+			BRIGHT_BLACK_COLOR, l.renderDate(), DEFAULT_COLOR,
+			l.message, indentExtra(l.extra), DEFAULT_COLOR,
 		)
 	}
 
 	return fmt.Sprintf(
 		"%s[%v]%s - %s%v%s: %s\n%s%s",
-		BRIGHT_BLACK_COLOR, l.date.Format(TimeFormat), DEFAULT_COLOR,
+		BRIGHT_BLACK_COLOR, l.renderDate(), DEFAULT_COLOR,
 		color, l.level, DEFAULT_COLOR,
-		l.message, IndentString(l.extra, 4), DEFAULT_COLOR,
+		l.message, indentExtra(l.extra), DEFAULT_COLOR,
 	)
 }
 
@@ -230,6 +711,18 @@ func (l Log) Date() time.Time {
 	return l.l.date
 }
 
+// Hour returns the date of the log truncated to the hour
+func (l Log) Hour() time.Time {
+	return truncateDate(l.l.date, time.Hour)
+}
+
+// Day returns the date of the log truncated to the start of the
+// day, in the location of the date
+func (l Log) Day() time.Time {
+	y, m, d := l.l.date.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, l.l.date.Location())
+}
+
 func (l Log) Message() string {
 	return l.l.cleanMessage()
 }
@@ -246,10 +739,53 @@ func (l Log) RawExtra() string {
 	return l.l.extra
 }
 
+// Index returns the storage index the log was given when it was
+// created, or -1 if the Logger was not tracking the sequence
+// (see Logger.EnableSequence)
+func (l Log) Index() int {
+	return l.l.seq
+}
+
+// Seq returns the process-wide sequence number given to the log when
+// it was created, which is increasing across every Logger and clone, so
+// it can be used to order or correlate the logs of a whole Logger tree.
+// Unlike Index, it does not depend on the storage. The counter restarts
+// with the process, so it's not increasing across the runs reopening the
+// same HugeLogger. It's included in the JSON only together with the index,
+// when the Logger tracks the sequence (see Logger.EnableSequence), and it
+// is 0 for logs decoded from JSON that did not include it
+func (l Log) Seq() uint64 {
+	return l.l.globalSeq
+}
+
+// Caller returns the source location (in the form dir/file.go:line)
+// that created the log, or an empty string if the Logger was not
+// tracking the callers (see Logger.EnableCaller)
+func (l Log) Caller() string {
+	return l.l.caller
+}
+
+// Fields returns the structured data associated with the log,
+// if any. The returned map must not be modified
+func (l Log) Fields() map[string]any {
+	return l.l.fields
+}
+
 func (l Log) Tags() []string {
 	return l.tags
 }
 
+// copy returns an independent copy of the log, which
+// is not yet stored in any Logger (see Index)
+func (l Log) copy() Log {
+	c := *l.l
+	c.seq = -1
+	return Log{
+		l:    &c,
+		tags: append([]string(nil), l.tags...),
+	}
+}
+
 func (l *Log) addTags(tags ...string) {
 loop:
 	for _, tag := range tags {
@@ -301,23 +837,223 @@ func (l Log) LevelMatchAny(levels ...LogLevel) bool {
 	return false
 }
 
+// ReplayTo creates in dst a new log with the same level, message and
+// extra of l, written to the dst output. Combined with Logger.Subscribe,
+// this can be used to forward the logs of a Logger to other ones
+func (l Log) ReplayTo(dst Logger) {
+	dst.AddLog(l.Level(), l.Message(), l.Extra(), true)
+}
+
+// ReplayToTagged is like ReplayTo, but the new log also keeps
+// the tags of l, in addition to the ones of dst
+func (l Log) ReplayToTagged(dst Logger) {
+	replay := Log{
+		l: newLog(l.Level(), l.Message(), l.Extra()),
+	}
+	replay.addTags(l.tags...)
+
+	dst.newLog(replay, true)
+}
+
 type logJSON struct {
-	ID      string    `json:"id"`
-	Level   LogLevel  `json:"level"`
-	Date    time.Time `json:"date"`
-	Message string    `json:"message"`
-	Extra   string    `json:"extra"`
-	Tags    []string  `json:"tags"`
+	ID        string         `json:"id"`
+	Level     LogLevel       `json:"level"`
+	Date      time.Time      `json:"date"`
+	Message   string         `json:"message"`
+	Extra     string         `json:"extra"`
+	Tags      []string       `json:"tags"`
+	Seq       *int           `json:"seq,omitempty"`
+	GlobalSeq uint64         `json:"global_seq,omitempty"`
+	Caller    string         `json:"caller,omitempty"`
+	Fields    map[string]any `json:"fields,omitempty"`
+}
+
+// jsonKeys maps the native JSON keys of a log to the ones
+// set with SetJSONKeys; it holds nil when no key is remapped
+var jsonKeys atomic.Pointer[map[string]string]
+
+// SetJSONKeys changes the keys used when the logs are exported in JSON, mapping
+// the native key (id, level, date, message, extra, tags, seq, global_seq, caller
+// or fields) to the new one, for example {"date": "@timestamp", "message": "msg"},
+// to match the schema expected by a log consumer. The keys are remapped by
+// LogsToJSON, LogsToJSONIndented, ExportJSONL, ExportGroupedJSON,
+// StreamLevelLogs, LogsReader and the network writers, while ImportJSONL
+// accepts both the native and the remapped keys; the storage of the
+// loggers, Log.JSON and json.Marshal always use the native keys. Keys
+// not in the map keep their name. An error is returned, and nothing is changed,
+// if a key is unknown or if two fields would end up with the same key. Passing
+// an empty map restores the native keys. It's safe to call concurrently
+// with the exports
+func SetJSONKeys(keys map[string]string) error {
+	if len(keys) == 0 {
+		jsonKeys.Store(nil)
+		return nil
+	}
+
+	native := []string{ "id", "level", "date", "message", "extra", "tags", "seq", "global_seq", "caller", "fields" }
+	final := make(map[string]string, len(native))
+	for _, key := range native {
+		final[key] = key
+	}
+
+	for from, to := range keys {
+		if _, ok := final[from]; !ok {
+			return fmt.Errorf("unknown log JSON key %q", from)
+		}
+		if to == "" {
+			return fmt.Errorf("empty JSON key for log field %q", from)
+		}
+		final[from] = to
+	}
+
+	used := make(map[string]string, len(final))
+	for _, from := range native {
+		to := final[from]
+		if other, ok := used[to]; ok {
+			return fmt.Errorf("log fields %q and %q both use the JSON key %q", other, from, to)
+		}
+		used[to] = from
+	}
+
+	remapped := make(map[string]string, len(keys))
+	for from, to := range keys {
+		if from != to {
+			remapped[from] = to
+		}
+	}
+	if len(remapped) == 0 {
+		jsonKeys.Store(nil)
+		return nil
+	}
+
+	jsonKeys.Store(&remapped)
+	return nil
+}
+
+// exportedJSON encodes the log like Log.JSON, with
+// the keys set with SetJSONKeys
+func exportedJSON(l Log) ([]byte, error) {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := jsonKeys.Load()
+	if keys == nil {
+		return data, nil
+	}
+	return remapJSONKeys(data, *keys)
+}
+
+// unmarshalExportedJSON decodes a log encoded with either the
+// native keys or the ones set with SetJSONKeys
+func unmarshalExportedJSON(data []byte, l *Log) error {
+	if keys := jsonKeys.Load(); keys != nil {
+		var err error
+		data, err = restoreJSONKeys(data, *keys)
+		if err != nil {
+			return err
+		}
+	}
+
+	return json.Unmarshal(data, l)
+}
+
+// remapJSONKeys renames the top-level keys of the encoded object data
+// with the given mapping, keeping their order
+func remapJSONKeys(data []byte, keys map[string]string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for first := true; dec.More(); first = false {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := t.(string)
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+
+		if newKey, ok := keys[key]; ok {
+			key = newKey
+		}
+		encodedKey, _ := json.Marshal(key)
+
+		if !first {
+			buf.WriteByte(',')
+		}
+		buf.Write(encodedKey)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// restoreJSONKeys renames the remapped keys back to the native
+// ones; a native key is kept only if its remapped one is missing
+func restoreJSONKeys(data []byte, keys map[string]string) ([]byte, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+
+	restored := make(map[string]json.RawMessage, len(obj))
+	for native, remapped := range keys {
+		if value, ok := obj[remapped]; ok {
+			restored[native] = value
+		}
+	}
+	for key, value := range obj {
+		if _, ok := restored[key]; ok {
+			continue
+		}
+		if _, ok := keys[key]; !ok && isRemappedKey(key, keys) {
+			continue
+		}
+		restored[key] = value
+	}
+
+	return json.Marshal(restored)
+}
+
+// isRemappedKey reports whether key is one of the remapped keys
+func isRemappedKey(key string, keys map[string]string) bool {
+	for _, remapped := range keys {
+		if remapped == key {
+			return true
+		}
+	}
+	return false
 }
 
 func (l Log) MarshalJSON() ([]byte, error) {
+	var seq *int
+	var globalSeq uint64
+	if l.l.seq >= 0 {
+		seq = &l.l.seq
+		globalSeq = l.Seq()
+	}
+
 	return json.Marshal(logJSON{
-		ID:      l.ID(),
-		Level:   l.Level(),
-		Date:    l.Date(),
-		Message: l.Message(),
-		Extra:   l.Extra(),
-		Tags:    l.Tags(),
+		ID:        l.ID(),
+		Level:     l.Level(),
+		Date:      l.Date(),
+		Message:   l.Message(),
+		Extra:     l.Extra(),
+		Tags:      l.Tags(),
+		Seq:       seq,
+		GlobalSeq: globalSeq,
+		Caller:    l.Caller(),
+		Fields:    l.Fields(),
 	})
 }
 
@@ -330,11 +1066,18 @@ func (l *Log) UnmarshalJSON(data []byte) error {
 	}
 
 	l.l = &log{
-		id:      decodedLog.ID,
-		level:   decodedLog.Level,
-		date:    decodedLog.Date,
-		message: decodedLog.Message,
-		extra:   decodedLog.Extra,
+		id:        decodedLog.ID,
+		level:     decodedLog.Level,
+		date:      decodedLog.Date,
+		message:   decodedLog.Message,
+		extra:     decodedLog.Extra,
+		seq:       -1,
+		globalSeq: decodedLog.GlobalSeq,
+		caller:    decodedLog.Caller,
+		fields:    decodedLog.Fields,
+	}
+	if decodedLog.Seq != nil {
+		l.l.seq = *decodedLog.Seq
 	}
 	l.tags = decodedLog.Tags
 
@@ -348,6 +1091,46 @@ func (l Log) JSON() []byte {
 	return b
 }
 
+// Debug returns a complete, multi-line dump of the log, including
+// its ID, tags, extra and fields, which is useful for inspecting a log
+// in tests or while debugging. Use String or Full for the normal output
+func (l Log) Debug() string {
+	var sb strings.Builder
+
+	sb.WriteString("Log {\n")
+	fmt.Fprintf(&sb, "  ID:      %s\n", l.ID())
+	fmt.Fprintf(&sb, "  Level:   %s\n", strings.TrimSpace(l.Level().String()))
+	fmt.Fprintf(&sb, "  Date:    %s\n", formatDate(l.Date()))
+	fmt.Fprintf(&sb, "  Message: %q\n", l.Message())
+	fmt.Fprintf(&sb, "  Tags:    %v\n", l.Tags())
+
+	if l.Index() >= 0 {
+		fmt.Fprintf(&sb, "  Index:   %d\n", l.Index())
+	}
+	if l.Caller() != "" {
+		fmt.Fprintf(&sb, "  Caller:  %s\n", l.Caller())
+	}
+	if extra := l.Extra(); extra != "" {
+		fmt.Fprintf(&sb, "  Extra:\n%s\n", IndentString(extra, 4))
+	}
+
+	if fields := l.Fields(); len(fields) > 0 {
+		keys := make([]string, 0, len(fields))
+		for key := range fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		sb.WriteString("  Fields:\n")
+		for _, key := range keys {
+			fmt.Fprintf(&sb, "    %s: %v\n", key, fields[key])
+		}
+	}
+
+	sb.WriteString("}")
+	return sb.String()
+}
+
 func (l Log) String() string {
 	return l.l.String()
 }
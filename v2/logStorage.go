@@ -2,11 +2,17 @@ package logger
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -15,169 +21,1342 @@ var LogFileTimeFormat = "06.01.02-15.04.05"
 
 var (
 	LogChunkSize = 1000
+	LogReadConcurrency = 4 // LogReadConcurrency is the maximum number of chunks read in parallel by GetLogsBuffered
 	LogFilePrefixLen = 4
 	LogFileExtension = "data"
+	CompressedFileExtension = "gz" // CompressedFileExtension is appended to the name of the chunk files compressed with gzip (see Logger.SetCompressRotated)
+	BlobFileExtension = "blob"
+	TTLSweepInterval = time.Second // TTLSweepInterval is how often the expired logs are purged from memory (see AddLogWithTTL)
 )
 
+// Errors returned by the storage read operations (see GetLogE, GetLogsE
+// and GetSpecificLogsE); they may be wrapped, so use errors.Is to check them
+var (
+	ErrLogNotFound = errors.New("log not found")
+	ErrLogExpired = errors.New("log expired")
+	ErrLogEvicted = errors.New("log deleted by the retention")
+	ErrCorruptChunk = errors.New("corrupt log chunk")
+	ErrIndexOutOfRange = errors.New("log index out of range")
+)
+
+// checkIndex returns an error wrapping ErrIndexOutOfRange
+// if index is not a valid index among n logs
+func checkIndex(index, n int) error {
+	if index < 0 || index >= n {
+		return fmt.Errorf("%w: index %d with %d logs", ErrIndexOutOfRange, index, n)
+	}
+	return nil
+}
+
+// checkRange returns an error wrapping ErrIndexOutOfRange if
+// [start, end) is not a valid range of indexes among n logs
+func checkRange(start, end, n int) error {
+	if start < 0 || end > n || start > end {
+		return fmt.Errorf("%w: range [%d, %d) with %d logs", ErrIndexOutOfRange, start, end, n)
+	}
+	return nil
+}
+
 type logStorage interface {
-	addLog(l Log) int
-	getLog(index int) Log
-	getLogs(start, end int) []Log
-	getSpecificLogs(logs []int) []Log
+	// addLog stores the log and returns its index; if seq is true the
+	// index is also saved in the log itself before it is stored
+	addLog(l Log, seq bool) (int, error)
+	// addLogs stores the logs in order and returns their indexes; if
+	// it fails, the indexes of the logs already stored are returned
+	addLogs(logs []Log, seq bool) ([]int, error)
+	addBlob(id string, blob []byte) error
+	getBlob(id string) ([]byte, error)
+	getLog(index int) (Log, error)
+	getLogs(start, end int) ([]Log, error)
+	getSpecificLogs(logs []int) ([]Log, error)
 	nLogs() int
+	close() error
+	// flush makes the logs stored so far durable, if the storage is on disk
+	flush() error
+	// setTTL schedules the removal of the log with the given index
+	setTTL(index int, ttl time.Duration) error
+	stats() StorageStats
+	setSyncPolicy(policy SyncPolicy)
+	setMaxFileBytes(n int64)
+	setCompressRotated(compress bool)
+	setRetention(maxChunks int)
+	setMaxAge(d time.Duration)
+	truncate(n int) error
+	removed() int
+	// firstIndex returns the index of the oldest log not deleted by the retention
+	firstIndex() int
+}
+
+type memLogStorage struct {
+	v []Log
+	blobs map[string][]byte
+	rwm *sync.RWMutex
+	deadlines map[int]time.Time // deadlines of the logs with a TTL not yet purged
+	expired map[int]struct{} // expired holds the indexes of the purged logs
+	sweeping bool
+	nRemoved int // nRemoved is the number of logs removed by truncate
+}
+
+func (s *memLogStorage) addLog(l Log, seq bool) (int, error) {
+	s.rwm.Lock()
+	defer s.rwm.Unlock()
+
+	if seq {
+		l.l.seq = len(s.v)
+	}
+
+	s.v = append(s.v, l)
+	return len(s.v)-1, nil
+}
+
+func (s *memLogStorage) addLogs(logs []Log, seq bool) ([]int, error) {
+	s.rwm.Lock()
+	defer s.rwm.Unlock()
+
+	res := make([]int, len(logs))
+	for i, l := range logs {
+		if seq {
+			l.l.seq = len(s.v)
+		}
+
+		s.v = append(s.v, l)
+		res[i] = len(s.v)-1
+	}
+	return res, nil
+}
+
+func (s *memLogStorage) addBlob(id string, blob []byte) error {
+	s.rwm.Lock()
+	defer s.rwm.Unlock()
+
+	if s.blobs == nil {
+		s.blobs = make(map[string][]byte)
+	}
+	s.blobs[id] = blob
+	return nil
+}
+
+func (s *memLogStorage) getBlob(id string) ([]byte, error) {
+	s.rwm.RLock()
+	defer s.rwm.RUnlock()
+
+	blob, ok := s.blobs[id]
+	if !ok {
+		return nil, fmt.Errorf("blob %s not found", id)
+	}
+	return blob, nil
+}
+
+func (s *memLogStorage) getLog(index int) (Log, error) {
+	s.rwm.RLock()
+	defer s.rwm.RUnlock()
+
+	if err := checkIndex(index, len(s.v)); err != nil {
+		return Log{}, err
+	}
+	if s.isExpired(index, time.Now()) {
+		return Log{}, fmt.Errorf("%w: log %d", ErrLogExpired, index)
+	}
+	return s.v[index], nil
+}
+
+func (s *memLogStorage) getLogs(start, end int) ([]Log, error) {
+	s.rwm.RLock()
+	defer s.rwm.RUnlock()
+
+	if err := checkRange(start, end, len(s.v)); err != nil {
+		return nil, err
+	}
+
+	if len(s.deadlines) == 0 && len(s.expired) == 0 {
+		return s.v[start:end], nil
+	}
+
+	now := time.Now()
+	res := make([]Log, 0, end-start)
+	for i := start; i < end; i++ {
+		if !s.isExpired(i, now) {
+			res = append(res, s.v[i])
+		}
+	}
+	return res, nil
+}
+
+func (s *memLogStorage) getSpecificLogs(logs []int) ([]Log, error) {
+	s.rwm.RLock()
+	defer s.rwm.RUnlock()
+
+	now := time.Now()
+	res := make([]Log, 0, len(logs))
+	for _, p := range logs {
+		if err := checkIndex(p, len(s.v)); err != nil {
+			return nil, err
+		}
+		if !s.isExpired(p, now) {
+			res = append(res, s.v[p])
+		}
+	}
+	return res, nil
+}
+
+// forEach calls fn with every log in the range [start, end), skipping
+// the expired ones, until fn returns false. The read lock is held for
+// the whole iteration
+func (s *memLogStorage) forEach(start, end int, fn func(i int, log Log) bool) error {
+	s.rwm.RLock()
+	defer s.rwm.RUnlock()
+
+	if err := checkRange(start, end, len(s.v)); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i := start; i < end; i++ {
+		if s.isExpired(i, now) {
+			continue
+		}
+		if !fn(i, s.v[i]) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// isExpired reports whether the log with the given index has been
+// purged or its TTL has passed, even if not yet purged. It must be
+// called with the lock held
+func (s *memLogStorage) isExpired(index int, now time.Time) bool {
+	if _, ok := s.expired[index]; ok {
+		return true
+	}
+	deadline, ok := s.deadlines[index]
+	return ok && !now.Before(deadline)
+}
+
+func (s *memLogStorage) stats() StorageStats {
+	s.rwm.RLock()
+	defer s.rwm.RUnlock()
+
+	return StorageStats{
+		Kind:    "memory",
+		Expired: len(s.expired),
+	}
+}
+
+func (s *memLogStorage) setSyncPolicy(policy SyncPolicy) {}
+
+func (s *memLogStorage) setMaxFileBytes(n int64) {}
+
+func (s *memLogStorage) setCompressRotated(compress bool) {}
+
+func (s *memLogStorage) setRetention(maxChunks int) {}
+
+func (s *memLogStorage) setMaxAge(d time.Duration) {}
+
+// truncate keeps only the last n logs, which get the indexes from 0
+func (s *memLogStorage) truncate(n int) error {
+	s.rwm.Lock()
+	defer s.rwm.Unlock()
+
+	cut := len(s.v) - n
+	if cut <= 0 {
+		return nil
+	}
+
+	// the logs purged by the TTL sweeper are empty
+	for _, l := range s.v[:cut] {
+		if l.l != nil {
+			delete(s.blobs, l.ID())
+		}
+	}
+
+	v := make([]Log, n)
+	copy(v, s.v[cut:])
+	s.v = v
+	s.nRemoved += cut
+
+	if s.deadlines != nil {
+		deadlines := make(map[int]time.Time, len(s.deadlines))
+		for index, deadline := range s.deadlines {
+			if index >= cut {
+				deadlines[index - cut] = deadline
+			}
+		}
+		s.deadlines = deadlines
+	}
+	if s.expired != nil {
+		expired := make(map[int]struct{}, len(s.expired))
+		for index := range s.expired {
+			if index >= cut {
+				expired[index - cut] = struct{}{}
+			}
+		}
+		s.expired = expired
+	}
+	return nil
+}
+
+func (s *memLogStorage) firstIndex() int {
+	return 0
+}
+
+func (s *memLogStorage) removed() int {
+	s.rwm.RLock()
+	defer s.rwm.RUnlock()
+	return s.nRemoved
+}
+
+func (s *memLogStorage) setTTL(index int, ttl time.Duration) error {
+	s.rwm.Lock()
+	defer s.rwm.Unlock()
+
+	if s.deadlines == nil {
+		s.deadlines = make(map[int]time.Time)
+	}
+	s.deadlines[index] = time.Now().Add(ttl)
+
+	if !s.sweeping {
+		s.sweeping = true
+		go s.sweep()
+	}
+	return nil
+}
+
+// sweep periodically purges the expired logs from memory, until
+// there are no more logs with a TTL
+func (s *memLogStorage) sweep() {
+	ticker := time.NewTicker(TTLSweepInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		s.rwm.Lock()
+
+		if s.expired == nil {
+			s.expired = make(map[int]struct{})
+		}
+		for index, deadline := range s.deadlines {
+			if now.Before(deadline) {
+				continue
+			}
+
+			s.v[index] = Log{}
+			s.expired[index] = struct{}{}
+			delete(s.deadlines, index)
+		}
+
+		if len(s.deadlines) == 0 {
+			s.sweeping = false
+			s.rwm.Unlock()
+			return
+		}
+		s.rwm.Unlock()
+	}
+}
+
+func (s *memLogStorage) nLogs() int {
+	s.rwm.RLock()
+	defer s.rwm.RUnlock()
+	return len(s.v)
+}
+
+// drain empties the storage and returns all the logs it had
+func (s *memLogStorage) drain() []Log {
+	s.rwm.Lock()
+	defer s.rwm.Unlock()
+
+	logs := make([]Log, 0, len(s.v))
+	now := time.Now()
+	for i, l := range s.v {
+		if !s.isExpired(i, now) {
+			logs = append(logs, l)
+		}
+	}
+
+	s.v = make([]Log, 0)
+	s.deadlines = nil
+	s.expired = nil
+	return logs
+}
+
+func (s *memLogStorage) close() error {
+	return nil
+}
+
+func (s *memLogStorage) flush() error {
+	return nil
+}
+
+type fileLogStorage struct {
+	n int
+	chunks int
+	cache []Log
+	cacheHead int
+	dir string
+	prefix string
+	f *os.File
+	starts []int // starts holds the index of the first log of every chunk file
+	size int64 // size is the number of bytes written in the chunk files
+	fileSize int64 // fileSize is the number of bytes written in the current chunk file
+	maxFileBytes int64 // maxFileBytes, if set, is the size that triggers the rollover
+	compressRotated bool // compressRotated is true if the completed chunk files are compressed
+	compressing *sync.WaitGroup // compressing tracks the chunk files being compressed
+	cm *sync.Mutex // cm guards compressingChunks
+	compressingChunks map[int]bool // compressingChunks holds the chunk files being compressed, set to true if deleted in the meantime
+	maxChunks int // maxChunks, if set, is the number of chunk files kept by the retention
+	maxAge time.Duration // maxAge, if set, is how long the completed chunk files are kept by the retention
+	offset int // offset is the number of logs removed by truncate, which is the index of the first log kept
+	retained int // retained is the index, not shifted by the offset, of the first log kept by the retention
+	rwm *sync.RWMutex
+	dirty bool // dirty is true if something was written after the last sync
+	stopSync chan struct{} // stopSync stops the periodic sync, if running
+	syncEveryLog bool // syncEveryLog is true if every write is synced
+}
+
+// storageDir returns the absolute path of the directory of the chunk
+// files, creating it (with its parents) if missing and create is true
+func storageDir(dir string, create bool) (string, error) {
+	if !filepath.IsAbs(dir) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", fmt.Errorf("resolving the relative path %q: %w", dir, err)
+		}
+		dir = wd + "/" + dir
+	}
+
+	if create {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", err
+		}
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", errors.New("the provided path is not a directory")
+	}
+
+	return dir, nil
+}
+
+func initFileLogStorage(dir, prefix string, createDir bool) (*fileLogStorage, error) {
+	dir, err := storageDir(dir, createDir)
+	if err != nil {
+		return nil, err
+	}
+
+	fls := &fileLogStorage{
+		cache: make([]Log, 0),
+		dir: dir,
+		prefix: fmt.Sprintf("%s-%s-", prefix, time.Now().Format(LogFileTimeFormat)),
+		starts: []int{ 0 },
+		compressing: new(sync.WaitGroup),
+		cm: new(sync.Mutex),
+		compressingChunks: make(map[int]bool),
+		rwm: new(sync.RWMutex),
+	}
+
+	fls.f, err = os.Create(fls.fileNameGeneration(0))
+	if err != nil {
+		return nil, err
+	}
+
+	return fls, nil
+}
+
+// ErrSessionNotFound is returned when the session to reopen, chosen
+// with HugeLoggerOptions.Session, is not found
+var ErrSessionNotFound = errors.New("log session not found")
+
+// openFileLogStorage reopens the most recent session of chunk files in dir
+// with the given prefix, or the one with the given session prefix if not
+// empty, so that new logs are appended to it. If no session is found, a new
+// one is created, unless a specific session was requested. A partially written last line of the last
+// chunk (left by a crash) is removed. Missing chunks are tolerated, and
+// reading their logs reports ErrLogNotFound, but the chunks before the last
+// one holding the last LogChunkSize logs are needed, to fill the cache
+func openFileLogStorage(dir, prefix, sessionPrefix string, createDir bool) (*fileLogStorage, error) {
+	dir, err := storageDir(dir, createDir)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := findChunkSessions(dir, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var session chunkSession
+	switch {
+	case sessionPrefix != "":
+		found := false
+		for _, s := range sessions {
+			if s.prefix == sessionPrefix {
+				session, found = s, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: %s in %s", ErrSessionNotFound, sessionPrefix, dir)
+		}
+	case len(sessions) == 0:
+		return initFileLogStorage(dir, prefix, false)
+	default:
+		session = sessions[len(sessions)-1]
+	}
+
+	fls := &fileLogStorage{
+		dir: dir,
+		prefix: session.prefix,
+		compressing: new(sync.WaitGroup),
+		cm: new(sync.Mutex),
+		compressingChunks: make(map[int]bool),
+		rwm: new(sync.RWMutex),
+	}
+
+	last := session.chunks[len(session.chunks)-1]
+	logs, err := recoverChunk(last.path, last.index)
+	if err != nil {
+		return nil, err
+	}
+
+	fls.chunks = last.index
+	if err = fls.loadStarts(last.index); err != nil {
+		return nil, err
+	}
+	fls.n = fls.starts[last.index] + len(logs)
+	if meta, err := readMeta(dir, session.prefix); err == nil && meta.First <= fls.n && meta.Retained <= fls.n {
+		fls.offset = meta.First
+		fls.retained = meta.Retained
+	}
+	firstChunk := fls.chunkOf(fls.firstKept())
+
+	// the cache holds the last LogChunkSize logs in order
+	// (with cacheHead at 0), so it may need the previous chunks
+	if len(logs) > LogChunkSize {
+		logs = logs[len(logs)-LogChunkSize:]
+	}
+	fls.cache = logs
+	for fNum := last.index - 1; fNum >= 0 && len(fls.cache) < LogChunkSize; fNum-- {
+		if fNum < firstChunk {
+			// the chunk was removed by truncate or by the retention, so its logs are never
+			// read, but their place in the cache must be kept
+			pad := LogChunkSize - len(fls.cache)
+			if fls.n < LogChunkSize {
+				pad = fls.n - len(fls.cache)
+			}
+			fls.cache = append(make([]Log, pad), fls.cache...)
+			break
+		}
+
+		prev, err := fls.loadChunk(fNum)
+		if err != nil {
+			return nil, err
+		}
+		if count := fls.starts[fNum+1] - fls.starts[fNum]; len(prev) != count {
+			return nil, fmt.Errorf("%w: chunk %d has %d logs instead of %d", ErrCorruptChunk, fNum, len(prev), count)
+		}
+
+		if missing := LogChunkSize - len(fls.cache); len(prev) > missing {
+			prev = prev[len(prev)-missing:]
+		}
+		fls.cache = append(prev, fls.cache...)
+	}
+
+	for _, chunk := range session.chunks {
+		if info, err := os.Stat(chunk.path); err == nil {
+			fls.size += info.Size()
+		}
+	}
+
+	// an empty last chunk (created just before a crash) is created
+	// again by the rollover of the next log, so the previous one
+	// is the current chunk
+	current := last.path
+	if fls.n == fls.starts[fls.chunks] && last.index > 0 {
+		fls.chunks --
+		fls.starts = fls.starts[:fls.chunks+1]
+		current = fls.fileNameGeneration(fls.chunks)
+
+		// the previous chunk may have been compressed after the rollover
+		if _, err := os.Stat(current); errors.Is(err, os.ErrNotExist) {
+			if err = gunzipFile(current + "." + CompressedFileExtension, current); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	fls.f, err = os.OpenFile(current, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := fls.f.Stat(); err == nil {
+		fls.fileSize = info.Size()
+	}
+
+	if err = fls.writeMeta(); err != nil {
+		fls.f.Close()
+		return nil, err
+	}
+	return fls, nil
+}
+
+// loadStarts fills the index of the first log of the chunks up to last,
+// using the counts recorded in the sidecar file of the session or, if it
+// is not available, counting the logs of every chunk. A missing chunk
+// is assumed to have LogChunkSize logs
+func (fls *fileLogStorage) loadStarts(last int) error {
+	counts := make([]int, last)
+
+	meta, err := readMeta(fls.dir, fls.prefix)
+	if err == nil && len(meta.Counts) >= last {
+		copy(counts, meta.Counts)
+	} else {
+		for fNum := range counts {
+			counts[fNum] = LogChunkSize
+
+			err := fls.readChunk(fNum, func(sc *bufio.Scanner) error {
+				counts[fNum] = 0
+				for sc.Scan() {
+					counts[fNum] ++
+				}
+				return sc.Err()
+			})
+			if err != nil && !errors.Is(err, ErrLogNotFound) {
+				return err
+			}
+		}
+	}
+
+	fls.starts = make([]int, last + 1)
+	for fNum, count := range counts {
+		fls.starts[fNum+1] = fls.starts[fNum] + count
+	}
+	return nil
+}
+
+// chunkOf returns the number of the chunk file holding the log
+func (fls *fileLogStorage) chunkOf(index int) int {
+	return sort.Search(len(fls.starts), func(fNum int) bool {
+		return fls.starts[fNum] > index
+	}) - 1
+}
+
+// recoverChunk reads all the logs of the chunk file, truncating a partially
+// written last line, if present. Any other line that can't be decoded is
+// reported as ErrCorruptChunk
+func recoverChunk(path string, fNum int) ([]Log, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var logs []Log
+	var valid int
+	for offset := 0; offset < len(b); {
+		end := bytes.IndexByte(b[offset:], '\n')
+		if end == -1 {
+			// partially written last line
+			break
+		}
+
+		var l Log
+		if err := unmarshalChunkLine(fNum, b[offset:offset+end], &l); err != nil {
+			if offset+end+1 < len(b) {
+				return nil, err
+			}
+			// the last line could be a partially written one
+			// that happens to end with a newline
+			break
+		}
+
+		logs = append(logs, l)
+		offset += end + 1
+		valid = offset
+	}
+
+	if valid < len(b) {
+		if err := os.Truncate(path, int64(valid)); err != nil {
+			return nil, err
+		}
+	}
+	return logs, nil
+}
+
+// loadChunk reads all the logs of the chunk with the given number
+func (fls *fileLogStorage) loadChunk(fNum int) ([]Log, error) {
+	var logs []Log
+	err := fls.readChunk(fNum, func(sc *bufio.Scanner) error {
+		for sc.Scan() {
+			var l Log
+			if err := unmarshalChunkLine(fNum, sc.Bytes(), &l); err != nil {
+				return err
+			}
+			logs = append(logs, l)
+		}
+		return sc.Err()
+	})
+	return logs, err
+}
+
+func (fls *fileLogStorage) fileNameGeneration(index int) string {
+	format := fmt.Sprintf("%%s/%%s%%0%dd.%s", LogFilePrefixLen, LogFileExtension)
+	return fmt.Sprintf(format, fls.dir, fls.prefix, index)
+}
+
+// chunkFile is a chunk file created by a HugeLogger found on disk
+type chunkFile struct {
+	index int
+	path  string
+}
+
+// chunkSession groups the chunk files created by the same HugeLogger,
+// which share the same file prefix
+type chunkSession struct {
+	prefix string
+	chunks []chunkFile
+}
+
+// parseChunkFileName splits the name of a chunk file generated by
+// fileNameGeneration into the session prefix and the chunk index
+func parseChunkFileName(name, prefix string) (session string, index int, ok bool) {
+	rest, found := strings.CutPrefix(name, prefix+"-")
+	if !found {
+		return
+	}
+
+	rest, _ = strings.CutSuffix(rest, "."+CompressedFileExtension)
+	rest, found = strings.CutSuffix(rest, "."+LogFileExtension)
+	if !found {
+		return
+	}
+
+	sep := strings.LastIndex(rest, "-")
+	if sep == -1 {
+		return
+	}
+
+	if _, err := time.Parse(LogFileTimeFormat, rest[:sep]); err != nil {
+		return
+	}
+
+	index, err := strconv.Atoi(rest[sep+1:])
+	if err != nil || index < 0 {
+		return
+	}
+
+	return name[:len(prefix)+sep+2], index, true
+}
+
+// findChunkSessions returns all the chunk files in dir created by
+// a HugeLogger with the given prefix, grouped by session. Sessions are
+// sorted from the oldest to the newest and chunks by their index
+func findChunkSessions(dir, prefix string) ([]chunkSession, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make(map[string][]chunkFile)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		session, index, ok := parseChunkFileName(entry.Name(), prefix)
+		if !ok {
+			continue
+		}
+
+		// a chunk can be found both uncompressed and compressed if the
+		// compression was interrupted, and the uncompressed one is kept
+		if chunks := sessions[session]; len(chunks) > 0 && chunks[len(chunks)-1].index == index {
+			if strings.HasSuffix(entry.Name(), "."+CompressedFileExtension) {
+				continue
+			}
+			sessions[session] = chunks[:len(chunks)-1]
+		}
+
+		sessions[session] = append(sessions[session], chunkFile{
+			index: index,
+			path:  filepath.Join(dir, entry.Name()),
+		})
+	}
+
+	res := make([]chunkSession, 0, len(sessions))
+	for session, chunks := range sessions {
+		sort.Slice(chunks, func(i, j int) bool {
+			return chunks[i].index < chunks[j].index
+		})
+		res = append(res, chunkSession{ prefix: session, chunks: chunks })
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].prefix < res[j].prefix
+	})
+
+	return res, nil
+}
+
+func (fls *fileLogStorage) blobFileName(id string) string {
+	return fmt.Sprintf("%s/%s%s.%s", fls.dir, fls.prefix, id, BlobFileExtension)
+}
+
+func (fls *fileLogStorage) addBlob(id string, blob []byte) error {
+	return os.WriteFile(fls.blobFileName(id), blob, 0644)
+}
+
+func (fls *fileLogStorage) getBlob(id string) ([]byte, error) {
+	return os.ReadFile(fls.blobFileName(id))
+}
+
+// addLog writes the log to the current chunk file. If the rollover, the
+// write or the sync required by SYNC_EVERY_LOG fails, the log is not stored
+// and the error is returned; a partially written line is removed, so that
+// the chunk file stays consistent
+func (fls *fileLogStorage) addLog(l Log, seq bool) (int, error) {
+	fls.rwm.Lock()
+	defer fls.rwm.Unlock()
+
+	// the rollover happens entirely under the write lock and before
+	// any other change, so the file handle is swapped only once the
+	// next chunk is ready and no write can target the closed one
+	if fls.needsRollover(0, 0) {
+		if err := fls.rollover(); err != nil {
+			return -1, err
+		}
+	}
+
+	// the index is known only after the retention,
+	// which can remove the oldest logs
+	p := fls.n - fls.offset
+	if seq {
+		l.l.seq = p
+	}
+
+	n, err := fls.f.Write(append(l.JSON(), '\n'))
+	if err == nil {
+		fls.dirty = true
+		err = fls.syncWrite()
+	}
+	if err != nil {
+		fls.f.Truncate(fls.fileSize)
+		l.l.seq = -1
+		return -1, err
+	}
+	fls.size += int64(n)
+	fls.fileSize += int64(n)
+
+	fls.cacheLog(l)
+	fls.n ++
+
+	return p, nil
+}
+
+// addLogs is like addLog, but the logs going to the same
+// chunk file are written all at once
+func (fls *fileLogStorage) addLogs(logs []Log, seq bool) ([]int, error) {
+	fls.rwm.Lock()
+	defer fls.rwm.Unlock()
+
+	res := make([]int, 0, len(logs))
+	var buf bytes.Buffer
+	var pending []Log
+
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+
+		n, err := fls.f.Write(buf.Bytes())
+		if err == nil {
+			fls.dirty = true
+			err = fls.syncWrite()
+		}
+		if err != nil {
+			fls.f.Truncate(fls.fileSize)
+			for _, l := range pending {
+				l.l.seq = -1
+			}
+			return err
+		}
+		fls.size += int64(n)
+		fls.fileSize += int64(n)
+
+		for _, l := range pending {
+			fls.cacheLog(l)
+			res = append(res, fls.n - fls.offset)
+			fls.n ++
+		}
+
+		buf.Reset()
+		pending = pending[:0]
+		return nil
+	}
+
+	for _, l := range logs {
+		if fls.needsRollover(len(pending), int64(buf.Len())) {
+			if err := flush(); err != nil {
+				return res, err
+			}
+			if err := fls.rollover(); err != nil {
+				return res, err
+			}
+		}
+
+		if seq {
+			l.l.seq = fls.n + len(pending) - fls.offset
+		}
+		buf.Write(l.JSON())
+		buf.WriteByte('\n')
+		pending = append(pending, l)
+	}
+
+	return res, flush()
+}
+
+// rollover closes the current chunk file, compressing it if needed,
+// and creates the next one, then applies the retention
+func (fls *fileLogStorage) rollover() error {
+	f, err := os.Create(fls.fileNameGeneration(fls.chunks + 1))
+	if err != nil {
+		return err
+	}
+
+	// with a sync policy, the logs of the completed
+	// chunk file must not wait for the close
+	if fls.dirty && (fls.syncEveryLog || fls.stopSync != nil) {
+		fls.f.Sync()
+	}
+	fls.dirty = false
+	fls.f.Close()
+	if fls.compressRotated {
+		fls.compressing.Add(1)
+		fls.cm.Lock()
+		fls.compressingChunks[fls.chunks] = false
+		fls.cm.Unlock()
+		go func(fNum int) {
+			defer fls.compressing.Done()
+			fls.compressChunk(fNum)
+		}(fls.chunks)
+	}
+
+	fls.f = f
+	fls.chunks ++
+	fls.starts = append(fls.starts, fls.n)
+	fls.fileSize = 0
+
+	// the new chunk file is in use even if the retention or the
+	// update of the sidecar file fail, which are reported anyway
+	return errors.Join(fls.applyRetention(), fls.writeMeta())
+}
+
+// cacheLog keeps the log among the last LogChunkSize ones held in memory
+func (fls *fileLogStorage) cacheLog(l Log) {
+	if len(fls.cache) < LogChunkSize {
+		fls.cache = append(fls.cache, l)
+	} else {
+		fls.cache[fls.cacheHead] = l
+		fls.cacheHead = (fls.cacheHead + 1) % len(fls.cache)
+	}
+}
+
+// needsRollover reports whether the current chunk file is full: it
+// has reached maxFileBytes, if set, or it has LogChunkSize logs. The
+// logs and the bytes not yet written to the file are counted too
+func (fls *fileLogStorage) needsRollover(pendingLogs int, pendingBytes int64) bool {
+	if fls.maxFileBytes > 0 {
+		return fls.fileSize + pendingBytes >= fls.maxFileBytes
+	}
+	return fls.n + pendingLogs - fls.starts[fls.chunks] >= LogChunkSize
+}
+
+func (fls *fileLogStorage) setMaxFileBytes(n int64) {
+	fls.rwm.Lock()
+	defer fls.rwm.Unlock()
+
+	fls.maxFileBytes = n
 }
 
-type memLogStorage struct {
-	v []Log
-	rwm *sync.RWMutex
+func (fls *fileLogStorage) setCompressRotated(compress bool) {
+	fls.rwm.Lock()
+	defer fls.rwm.Unlock()
+
+	fls.compressRotated = compress
 }
 
-func (s *memLogStorage) addLog(l Log) int {
-	s.rwm.Lock()
-	defer s.rwm.Unlock()
+func (fls *fileLogStorage) setRetention(maxChunks int) {
+	fls.rwm.Lock()
+	defer fls.rwm.Unlock()
 
-	s.v = append(s.v, l)
-	return len(s.v)-1
+	fls.maxChunks = maxChunks
 }
 
-func (s memLogStorage) getLog(index int) Log {
-	s.rwm.RLock()
-	defer s.rwm.RUnlock()
-	return s.v[index]
+func (fls *fileLogStorage) setMaxAge(d time.Duration) {
+	fls.rwm.Lock()
+	defer fls.rwm.Unlock()
+
+	fls.maxAge = d
 }
 
-func (s memLogStorage) getLogs(start, end int) []Log {
-	s.rwm.RLock()
-	defer s.rwm.RUnlock()
-	return s.v[start:end]
+// applyRetention deletes the oldest chunk files exceeding maxChunks or
+// older than maxAge, moving retained to the first log of the oldest chunk
+// kept; unlike truncate, the indexes of the logs don't change. The current
+// chunk file is never deleted
+func (fls *fileLogStorage) applyRetention() error {
+	first := fls.chunkOf(fls.firstKept())
+	keep := first
+
+	if fls.maxChunks > 0 && fls.chunks + 1 - fls.maxChunks > keep {
+		keep = fls.chunks + 1 - fls.maxChunks
+	}
+	if fls.maxAge > 0 {
+		limit := time.Now().Add(-fls.maxAge)
+		for keep < fls.chunks && fls.chunkModTime(keep).Before(limit) {
+			keep ++
+		}
+	}
+
+	if keep == first {
+		return nil
+	}
+
+	// the logs are unavailable even if not all
+	// of their chunk files could be deleted
+	fls.retained = fls.starts[keep]
+	return fls.removeChunks(first, keep)
 }
 
-func (s memLogStorage) getSpecificLogs(logs []int) []Log {
-	s.rwm.RLock()
-	defer s.rwm.RUnlock()
+// firstKept returns the index, not shifted by the offset, of
+// the oldest log not removed by truncate or by the retention
+func (fls *fileLogStorage) firstKept() int {
+	return max(fls.offset, fls.retained)
+}
 
-	res := make([]Log, 0, len(logs))
-	for _, p := range logs {
-		res = append(res, s.v[p])
+// checkEvicted returns an error wrapping ErrLogEvicted if the log with
+// the given index, not shifted by the offset, was deleted by the retention
+func (fls *fileLogStorage) checkEvicted(index int) error {
+	if index < fls.retained {
+		return fmt.Errorf("%w: log %d, the first available is %d", ErrLogEvicted, index - fls.offset, fls.retained - fls.offset)
 	}
-	return res
+	return nil
 }
 
-func (s memLogStorage) nLogs() int {
-	return len(s.v)
+// chunkModTime returns the time of the last write of the chunk file
+// with the given number, compressed or not, or the zero time if
+// the file is missing
+func (fls *fileLogStorage) chunkModTime(fNum int) time.Time {
+	name := fls.fileNameGeneration(fNum)
+	for _, path := range []string{ name, name + "." + CompressedFileExtension } {
+		if info, err := os.Stat(path); err == nil {
+			return info.ModTime()
+		}
+	}
+	return time.Time{}
 }
 
-type fileLogStorage struct {
-	n int
-	chunks int
-	cache []Log
-	cacheHead int
-	dir string
-	prefix string
-	f *os.File
-	rwm *sync.RWMutex
+// removeChunks deletes the chunk files from the number from to the
+// number to (excluded), compressed or not, updating the size. A chunk file
+// being compressed is deleted by compressChunk when done, so that the
+// writes never wait for a compression
+func (fls *fileLogStorage) removeChunks(from, to int) error {
+	for fNum := from; fNum < to; fNum++ {
+		name := fls.fileNameGeneration(fNum)
+
+		fls.cm.Lock()
+		_, compressing := fls.compressingChunks[fNum]
+		if compressing {
+			fls.compressingChunks[fNum] = true
+		}
+		fls.cm.Unlock()
+
+		if compressing {
+			if info, err := os.Stat(name); err == nil {
+				fls.size -= info.Size()
+			}
+			continue
+		}
+
+		for _, path := range []string{ name, name + "." + CompressedFileExtension } {
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			if err = os.Remove(path); err != nil {
+				return err
+			}
+			fls.size -= info.Size()
+		}
+	}
+
+	return nil
 }
 
-func initFileLogStorage(dir, prefix string) (*fileLogStorage, error) {
-	if !filepath.IsAbs(dir) {
-		wd, _ := os.Getwd()
-		dir = wd + "/" + dir
+// compressChunk compresses the completed chunk file with the given number.
+// The compressed file replaces the original one only once it is complete,
+// so readers always find one of the two; if anything fails, the original
+// file is kept. If the chunk file was deleted by removeChunks in the
+// meantime, both files are deleted
+func (fls *fileLogStorage) compressChunk(fNum int) error {
+	name := fls.fileNameGeneration(fNum)
+	gzName := name + "." + CompressedFileExtension
+
+	err := gzipFile(name, gzName + ".tmp")
+
+	fls.cm.Lock()
+	defer fls.cm.Unlock()
+
+	deleted := fls.compressingChunks[fNum]
+	delete(fls.compressingChunks, fNum)
+	if err != nil || deleted {
+		os.Remove(gzName + ".tmp")
+		if deleted {
+			return os.Remove(name)
+		}
+		return err
 	}
-	
-	fls := &fileLogStorage{
-		cache: make([]Log, 0),
-		dir: dir,
-		prefix: fmt.Sprintf("%s-%s-", prefix, time.Now().Format(LogFileTimeFormat)),
-		rwm: new(sync.RWMutex),
+
+	// the compressed file keeps the time of the last write, used by
+	// the retention (see SetMaxAge)
+	if info, err := os.Stat(name); err == nil {
+		os.Chtimes(gzName + ".tmp", info.ModTime(), info.ModTime())
+	}
+	if err := os.Rename(gzName + ".tmp", gzName); err != nil {
+		os.Remove(gzName + ".tmp")
+		return err
 	}
+	return os.Remove(name)
+}
 
-	info, err := os.Stat(dir)
+// gzipFile writes the content of the file src compressed with gzip to dst
+func gzipFile(src, dst string) error {
+	in, err := os.Open(src)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer in.Close()
 
-	if !info.IsDir() {
-		return nil, errors.New("the provided path is not a directory")
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
 	}
+	defer out.Close()
 
-	fls.f, err = os.Create(fls.fileNameGeneration(0))
+	gz := gzip.NewWriter(out)
+	if _, err = io.Copy(gz, in); err != nil {
+		return err
+	}
+	if err = gz.Close(); err != nil {
+		return err
+	}
+	return out.Sync()
+}
+
+// gunzipFile writes the content of the gzip file src uncompressed to dst,
+// then removes src
+func gunzipFile(src, dst string) error {
+	in, err := os.Open(src)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer in.Close()
 
-	return fls, nil
-}
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCorruptChunk, err)
+	}
 
-func (fls *fileLogStorage) fileNameGeneration(index int) string {
-	format := fmt.Sprintf("%%s/%%s%%0%dd.%s", LogFilePrefixLen, LogFileExtension)
-	return fmt.Sprintf(format, fls.dir, fls.prefix, index)
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, gz); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return fmt.Errorf("%w: %v", ErrCorruptChunk, err)
+	}
+	if err = out.Close(); err != nil {
+		return err
+	}
+
+	in.Close()
+	return os.Remove(src)
 }
 
-func (fls *fileLogStorage) addLog(l Log) int {
+func (fls *fileLogStorage) setSyncPolicy(policy SyncPolicy) {
 	fls.rwm.Lock()
 	defer fls.rwm.Unlock()
 
-	p := fls.n
-	if len(fls.cache) < LogChunkSize {
-		fls.cache = append(fls.cache, l)
-	} else {
-		fls.cache[fls.cacheHead] = l
-		fls.cacheHead = (fls.cacheHead + 1) % len(fls.cache)
+	if fls.stopSync != nil {
+		close(fls.stopSync)
+		fls.stopSync = nil
+	}
+
+	fls.syncEveryLog = policy == SYNC_EVERY_LOG
+	if fls.syncEveryLog && fls.dirty {
+		fls.f.Sync()
+		fls.dirty = false
+	}
+	if policy <= 0 {
+		return
+	}
 
-		if fls.n % LogChunkSize == 0 {
-			fls.f.Close()
-			fls.chunks ++
+	stop := make(chan struct{})
+	fls.stopSync = stop
+	go fls.syncEvery(time.Duration(policy), stop)
+}
 
-			f, err := os.Create(fls.fileNameGeneration(fls.chunks))
-			if err != nil {
-				panic(err)
-			}
-			fls.f = f
-		}
+// syncWrite syncs the current chunk file after a write, if every
+// write must be synced, and returns the error of the sync; if the
+// sync fails, the file is left dirty, so it's synced again later
+func (fls *fileLogStorage) syncWrite() error {
+	if !fls.syncEveryLog {
+		return nil
 	}
-	fls.n ++
+	if err := fls.f.Sync(); err != nil {
+		return err
+	}
+	fls.dirty = false
+	return nil
+}
 
-	fls.f.Write(l.JSON())
-	fls.f.Write([]byte{'\n'})
-	return p
+// syncEvery syncs the current chunk file every d, if anything
+// was written in the meantime, until stop is closed. The sync
+// runs without holding the lock, so it never holds up the writes
+func (fls *fileLogStorage) syncEvery(d time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+
+		fls.rwm.RLock()
+		f, dirty, size := fls.f, fls.dirty, fls.size
+		fls.rwm.RUnlock()
+		if !dirty {
+			continue
+		}
+
+		// if the file was written or replaced by the rollover in the
+		// meantime (which syncs it before closing it), it stays dirty
+		err := f.Sync()
+
+		fls.rwm.Lock()
+		if err == nil && fls.f == f && fls.size == size {
+			fls.dirty = false
+		}
+		fls.rwm.Unlock()
+	}
 }
 
-func (fls *fileLogStorage) getLog(index int) Log {
+func (fls *fileLogStorage) getLog(index int) (Log, error) {
 	fls.rwm.RLock()
 	defer fls.rwm.RUnlock()
 
+	if err := checkIndex(index, fls.n - fls.offset); err != nil {
+		return Log{}, err
+	}
+	if err := fls.checkEvicted(index + fls.offset); err != nil {
+		return Log{}, err
+	}
+	return fls.getLogLocked(index + fls.offset)
+}
+
+// getLogLocked is getLog without locking and with the index not shifted
+// by the offset, used by the read methods that already hold the read lock:
+// a recursive read lock would deadlock with a concurrent addLog waiting
+// for the write lock
+func (fls *fileLogStorage) getLogLocked(index int) (Log, error) {
 	switch {
 	case fls.n <= LogChunkSize: {
-		return fls.cache[index]
+		return fls.cache[index], nil
 	}
 	case index >= fls.n - LogChunkSize:
 		index = index - (fls.n - LogChunkSize) + fls.cacheHead
 		index %= LogChunkSize
-		return fls.cache[index]
+		return fls.cache[index], nil
 	}
 
-	fNum := index / LogChunkSize
-	index = index % LogChunkSize
+	fNum := fls.chunkOf(index)
+	index -= fls.starts[fNum]
+
+	var l Log
+	err := fls.readChunk(fNum, func(sc *bufio.Scanner) error {
+		for i := 0; i <= index; i++ {
+			if err := scanChunkLine(fNum, sc); err != nil {
+				return err
+			}
+		}
+
+		return unmarshalChunkLine(fNum, sc.Bytes(), &l)
+	})
+
+	return l, err
+}
+
+// readChunk opens the chunk file with the given number, compressed or
+// not, and calls read with a scanner over its lines, closing the file as
+// soon as read returns. A missing chunk file is reported as ErrLogNotFound
+func (fls *fileLogStorage) readChunk(fNum int, read func(sc *bufio.Scanner) error) error {
+	name := fls.fileNameGeneration(fNum)
+	compressed := false
 
-	f, err := os.Open(fls.fileNameGeneration(fNum))
+	f, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		f, err = os.Open(name + "." + CompressedFileExtension)
+		compressed = err == nil
+	}
 	if err != nil {
-		panic(err)
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: chunk %d is missing: %v", ErrLogNotFound, fNum, err)
+		}
+		return err
 	}
 	defer f.Close()
 
-	sc := bufio.NewScanner(f)
-	for i := 0; i < index; i++ {
-		sc.Scan()
+	return scanChunk(f, compressed, fNum, read)
+}
+
+// scanChunk calls read with a scanner over the lines of the chunk
+// file with the given number, decompressing it if compressed
+func scanChunk(r io.Reader, compressed bool, fNum int, read func(sc *bufio.Scanner) error) error {
+	if compressed {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("%w: chunk %d: %v", ErrCorruptChunk, fNum, err)
+		}
+		defer gz.Close()
+		r = gz
 	}
-	sc.Scan()
-	
-	var l Log
-	err = json.Unmarshal(sc.Bytes(), &l)
-	if err != nil {
-		panic(err)
+
+	return read(bufio.NewScanner(r))
+}
+
+// scanChunkLine advances the scanner to the next line of the chunk file
+// with the given number, returning an error wrapping ErrCorruptChunk
+// if the file can't be read or has fewer lines than expected
+func scanChunkLine(fNum int, sc *bufio.Scanner) error {
+	if sc.Scan() {
+		return nil
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("%w: chunk %d: %v", ErrCorruptChunk, fNum, err)
 	}
+	return fmt.Errorf("%w: chunk %d is shorter than expected", ErrCorruptChunk, fNum)
+}
 
-	return l
+// unmarshalChunkLine decodes a line read from the chunk file with the given
+// number, reporting a decoding failure as ErrCorruptChunk
+func unmarshalChunkLine(fNum int, line []byte, l *Log) error {
+	err := json.Unmarshal(line, l)
+	if err != nil {
+		return fmt.Errorf("%w: chunk %d: %v", ErrCorruptChunk, fNum, err)
+	}
+	return nil
 }
 
 type interval struct {
@@ -205,11 +1384,13 @@ func (fls fileLogStorage) splitRequestRange(start, end int) (res []interval) {
 	}
 
 	inter := interval{ start: start, end: start+1 }
+	fNum := fls.chunkOf(start)
 	
 	for i := start+1; i < end; i++ {
-		if i % LogChunkSize == 0 {
+		if fNum+1 < len(fls.starts) && i == fls.starts[fNum+1] {
 			res = append(res, inter)
 			inter = interval{ start: i, end: i+1 }
+			fNum ++
 		} else {
 			inter.end ++
 		}
@@ -219,47 +1400,64 @@ func (fls fileLogStorage) splitRequestRange(start, end int) (res []interval) {
 	return
 }
 
-func (fls*fileLogStorage) getLogs(start, end int) []Log {
+func (fls*fileLogStorage) getLogs(start, end int) ([]Log, error) {
 	fls.rwm.RLock()
 	defer fls.rwm.RUnlock()
 
+	if err := checkRange(start, end, fls.n - fls.offset); err != nil {
+		return nil, err
+	}
+	start, end = start + fls.offset, end + fls.offset
+	if start < end {
+		if err := fls.checkEvicted(start); err != nil {
+			return nil, err
+		}
+	}
+
 	inter := fls.splitRequestRange(start, end)
 	res := make([]Log, 0, end-start)
 
 	for _, x := range inter {
 		if x.start >= fls.n - LogChunkSize {
 			for i := x.start; i < x.end; i++ {
-				res = append(res, fls.getLog(i))
+				l, err := fls.getLogLocked(i)
+				if err != nil {
+					return nil, err
+				}
+				res = append(res, l)
 			}
 		} else {
-			fNum := x.start / LogChunkSize
+			fNum := fls.chunkOf(x.start)
 
-			f, err := os.Open(fls.fileNameGeneration(fNum))
-			if err != nil {
-				panic(err)
-			}
-			defer f.Close()
+			err := fls.readChunk(fNum, func(sc *bufio.Scanner) error {
+				for i := fls.starts[fNum]; i < x.start; i++ {
+					if err := scanChunkLine(fNum, sc); err != nil {
+						return err
+					}
+				}
 
-			sc := bufio.NewScanner(f)
-			for i := fNum * LogChunkSize; i < x.start; i++ {
-				sc.Scan()
-			}
+				for i := x.start; i < x.end; i++ {
+					if err := scanChunkLine(fNum, sc); err != nil {
+						return err
+					}
 
-			for i := x.start; i < x.end; i++ {
-				sc.Scan()
-				
-				var l Log
-				err = json.Unmarshal(sc.Bytes(), &l)
-				if err != nil {
-					panic(err)
-				}
+					var l Log
+					err := unmarshalChunkLine(fNum, sc.Bytes(), &l)
+					if err != nil {
+						return err
+					}
 
-				res = append(res, l)
+					res = append(res, l)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
 			}
 		}
 	}
 
-	return res
+	return res, nil
 }
 
 func (fls fileLogStorage) splitRequestSingle(logs []int) (res [][]int) {
@@ -292,7 +1490,7 @@ func (fls fileLogStorage) splitRequestSingle(logs []int) (res [][]int) {
 
 	inter := []int{logs[0]}
 	for i := 1; i < len(logs); i++ {
-		if logs[i] / LogChunkSize == inter[0] / LogChunkSize {
+		if fls.chunkOf(logs[i]) == fls.chunkOf(inter[0]) {
 			inter = append(inter, logs[i])
 			continue
 		}
@@ -305,52 +1503,234 @@ func (fls fileLogStorage) splitRequestSingle(logs []int) (res [][]int) {
 	return
 }
 
-func (fls*fileLogStorage) getSpecificLogs(logs []int) []Log {
+func (fls*fileLogStorage) getSpecificLogs(logs []int) ([]Log, error) {
 	fls.rwm.RLock()
 	defer fls.rwm.RUnlock()
 
+	for _, p := range logs {
+		if err := checkIndex(p, fls.n - fls.offset); err != nil {
+			return nil, err
+		}
+		if err := fls.checkEvicted(p + fls.offset); err != nil {
+			return nil, err
+		}
+	}
+
+	if fls.offset > 0 {
+		shifted := make([]int, len(logs))
+		for i, p := range logs {
+			shifted[i] = p + fls.offset
+		}
+		logs = shifted
+	}
+
 	inter := fls.splitRequestSingle(logs)
 	res := make([]Log, 0, len(logs))
 
 	for _, i := range inter {
 		if i[0] >= fls.n - LogChunkSize {
 			for _, p := range i {
-				res = append(res, fls.getLog(p))
+				l, err := fls.getLogLocked(p)
+				if err != nil {
+					return nil, err
+				}
+				res = append(res, l)
 			}
 		} else {
-			fNum := i[0] / LogChunkSize
+			fNum := fls.chunkOf(i[0])
+
+			err := fls.readChunk(fNum, func(sc *bufio.Scanner) error {
+				lastRead := fls.starts[fNum] - 1
 
-			f, err := os.Open(fls.fileNameGeneration(fNum))
+				for _, p := range i {
+					for j := lastRead + 1; j <= p; j++ {
+						if err := scanChunkLine(fNum, sc); err != nil {
+							return err
+						}
+					}
+					lastRead = p
+
+					var l Log
+					err := unmarshalChunkLine(fNum, sc.Bytes(), &l)
+					if err != nil {
+						return err
+					}
+
+					res = append(res, l)
+				}
+				return nil
+			})
 			if err != nil {
-				panic(err)
+				return nil, err
 			}
-			defer f.Close()
+		}
+	}
 
-			sc := bufio.NewScanner(f)
-			lastRead := (fNum * LogChunkSize) - 1
+	return res, nil
+}
 
-			for _, p := range i {
-				for j := lastRead + 1; j < p; j++ {
-					sc.Scan()
-				}
+func (fls *fileLogStorage) stats() StorageStats {
+	fls.rwm.RLock()
+	defer fls.rwm.RUnlock()
+
+	return StorageStats{
+		Kind:   "file",
+		Dir:    fls.dir,
+		Prefix: strings.TrimSuffix(fls.prefix, "-"),
+		Chunks: fls.chunks + 1 - fls.chunkOf(fls.firstKept()),
+		Bytes:  fls.size,
+	}
+}
 
-				sc.Scan()
-				lastRead = p
+func (fls *fileLogStorage) setTTL(index int, ttl time.Duration) error {
+	return ErrTTLUnsupported
+}
 
-				var l Log
-				err = json.Unmarshal(sc.Bytes(), &l)
-				if err != nil {
-					panic(err)
-				}
+func (fls *fileLogStorage) nLogs() int {
+	fls.rwm.RLock()
+	defer fls.rwm.RUnlock()
+	return fls.n - fls.offset
+}
 
-				res = append(res, l)
-			}
+// truncate keeps only the last n logs, which get the indexes from 0,
+// deleting the chunk files holding only older logs
+func (fls *fileLogStorage) truncate(n int) error {
+	fls.rwm.Lock()
+	defer fls.rwm.Unlock()
+
+	first := fls.n - n
+	if first <= fls.offset {
+		return nil
+	}
+
+	if err := fls.removeChunks(fls.chunkOf(fls.firstKept()), fls.chunkOf(first)); err != nil {
+		return err
+	}
+
+	fls.offset = first
+	return fls.writeMeta()
+}
+
+func (fls *fileLogStorage) firstIndex() int {
+	fls.rwm.RLock()
+	defer fls.rwm.RUnlock()
+	return max(0, fls.retained - fls.offset)
+}
+
+func (fls *fileLogStorage) removed() int {
+	fls.rwm.RLock()
+	defer fls.rwm.RUnlock()
+	return fls.offset
+}
+
+// logMeta is the content of the sidecar file of a HugeLogger session,
+// which records how many logs are stored in each chunk file, so that
+// a session can be inspected or reopened without scanning every chunk
+type logMeta struct {
+	N      int   `json:"n"`
+	Chunks int   `json:"chunks"`
+	Counts []int `json:"counts"`
+	First  int   `json:"first,omitempty"`
+	Retained int `json:"retained,omitempty"`
+}
+
+// firstChunk returns the number of the chunk holding the oldest log
+// not removed by truncate or by the retention, like chunkOf(firstKept())
+func (meta logMeta) firstChunk() int {
+	first := max(meta.First, meta.Retained)
+
+	end := 0
+	for i, count := range meta.Counts {
+		end += count
+		if end > first {
+			return i
+		}
+	}
+	return max(0, len(meta.Counts) - 1)
+}
+
+// metaFileName returns the name of the sidecar file of the
+// session with the given prefix
+func metaFileName(dir, sessionPrefix string) string {
+	return fmt.Sprintf("%s/%s.meta", dir, strings.TrimSuffix(sessionPrefix, "-"))
+}
+
+// writeMeta updates the sidecar file of the session; the file is replaced
+// atomically, so a crash never leaves a partially written one
+func (fls *fileLogStorage) writeMeta() error {
+	meta := logMeta{
+		N:      fls.n,
+		Chunks: fls.chunks + 1,
+		Counts: make([]int, fls.chunks + 1),
+		First:  fls.offset,
+		Retained: fls.retained,
+	}
+	for i := range meta.Counts {
+		if i < fls.chunks {
+			meta.Counts[i] = fls.starts[i+1] - fls.starts[i]
+		} else {
+			meta.Counts[i] = fls.n - fls.starts[i]
 		}
 	}
 
-	return res
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	name := metaFileName(fls.dir, fls.prefix)
+	if err = os.WriteFile(name + ".tmp", b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(name + ".tmp", name)
 }
 
-func (fls *fileLogStorage) nLogs() int {
-	return fls.n
+// readMeta reads the sidecar file of the session with the given prefix
+func readMeta(dir, sessionPrefix string) (logMeta, error) {
+	var meta logMeta
+
+	b, err := os.ReadFile(metaFileName(dir, sessionPrefix))
+	if err != nil {
+		return meta, err
+	}
+
+	err = json.Unmarshal(b, &meta)
+	return meta, err
+}
+
+// flush updates the sidecar file of the session and syncs the current
+// chunk file, like close, without closing it
+func (fls *fileLogStorage) flush() error {
+	fls.rwm.Lock()
+	defer fls.rwm.Unlock()
+
+	err := fls.writeMeta()
+	if fls.dirty {
+		if syncErr := fls.f.Sync(); err == nil {
+			err = syncErr
+		}
+		fls.dirty = false
+	}
+	return err
+}
+
+func (fls *fileLogStorage) close() error {
+	fls.rwm.Lock()
+	defer fls.rwm.Unlock()
+
+	if fls.stopSync != nil {
+		close(fls.stopSync)
+		fls.stopSync = nil
+	}
+	fls.compressing.Wait()
+
+	err := fls.writeMeta()
+	if fls.dirty {
+		fls.f.Sync()
+		fls.dirty = false
+	}
+	if closeErr := fls.f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
 }
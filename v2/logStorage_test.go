@@ -0,0 +1,456 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// setChunkSize changes LogChunkSize for the duration of the test
+func setChunkSize(t *testing.T, n int) {
+	old := LogChunkSize
+	LogChunkSize = n
+	t.Cleanup(func() { LogChunkSize = old })
+}
+
+func newTestHugeLogger(t *testing.T) (Logger, string) {
+	dir := t.TempDir()
+	l, err := NewHugeLogger(nil, dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l, dir
+}
+
+func TestRetentionKeepsIndexes(t *testing.T) {
+	setChunkSize(t, 10)
+	l, dir := newTestHugeLogger(t)
+	l.SetRetention(2)
+
+	for i := 0; i < 50; i++ {
+		l.AddLog(LOG_LEVEL_INFO, fmt.Sprintf("log %d", i), "", false)
+	}
+
+	if n := l.NLogs(); n != 50 {
+		t.Fatalf("NLogs = %d, want 50", n)
+	}
+	if first := l.FirstIndex(); first != 30 {
+		t.Fatalf("FirstIndex = %d, want 30", first)
+	}
+
+	if _, err := l.GetLogE(0); !errors.Is(err, ErrLogEvicted) {
+		t.Errorf("GetLogE(0) error = %v, want ErrLogEvicted", err)
+	}
+	if _, err := l.GetLogsE(25, 35); !errors.Is(err, ErrLogEvicted) {
+		t.Errorf("GetLogsE(25, 35) error = %v, want ErrLogEvicted", err)
+	}
+	if _, err := l.GetSpecificLogsE([]int{ 31, 29 }); !errors.Is(err, ErrLogEvicted) {
+		t.Errorf("GetSpecificLogsE error = %v, want ErrLogEvicted", err)
+	}
+
+	for _, i := range []int{ 30, 35, 49 } {
+		log, err := l.GetLogE(i)
+		if err != nil {
+			t.Fatalf("GetLogE(%d): %v", i, err)
+		}
+		if want := fmt.Sprintf("log %d", i); log.Message() != want {
+			t.Errorf("GetLogE(%d) = %q, want %q", i, log.Message(), want)
+		}
+	}
+
+	var buf bytes.Buffer
+	n, err := l.ExportJSONL(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 20 {
+		t.Errorf("ExportJSONL exported %d logs, want 20", n)
+	}
+
+	if err = l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := OpenHugeLogger(nil, dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+
+	if first := reopened.FirstIndex(); first != 30 {
+		t.Errorf("FirstIndex after reopening = %d, want 30", first)
+	}
+	if log, err := reopened.GetLogE(40); err != nil || log.Message() != "log 40" {
+		t.Errorf("GetLogE(40) after reopening = %q, %v", log.Message(), err)
+	}
+}
+
+func TestRetentionCloneIndexes(t *testing.T) {
+	setChunkSize(t, 10)
+	l, _ := newTestHugeLogger(t)
+	l.SetRetention(2)
+	c := l.Clone(nil)
+
+	for i := 0; i < 50; i++ {
+		c.AddLog(LOG_LEVEL_INFO, fmt.Sprintf("log %d", i), "", false)
+	}
+
+	if _, err := c.GetLogE(10); !errors.Is(err, ErrLogEvicted) {
+		t.Errorf("clone GetLogE(10) error = %v, want ErrLogEvicted", err)
+	}
+	if first := c.FirstIndex(); first != 30 {
+		t.Errorf("clone FirstIndex = %d, want 30", first)
+	}
+	if log, err := c.GetLogE(45); err != nil || log.Message() != "log 45" {
+		t.Errorf("clone GetLogE(45) = %q, %v", log.Message(), err)
+	}
+}
+
+func TestRetentionWithCompression(t *testing.T) {
+	setChunkSize(t, 10)
+	l, dir := newTestHugeLogger(t)
+	l.SetCompressRotated(true)
+	l.SetRetention(1)
+
+	for i := 0; i < 200; i++ {
+		l.AddLog(LOG_LEVEL_INFO, fmt.Sprintf("log %d", i), "", false)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chunks []string
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasSuffix(name, ".tmp") {
+			t.Errorf("temporary file %s left behind", name)
+		}
+		if strings.HasSuffix(name, "." + LogFileExtension) || strings.HasSuffix(name, "." + CompressedFileExtension) {
+			chunks = append(chunks, name)
+		}
+	}
+	if len(chunks) != 1 {
+		t.Errorf("found the chunk files %v, want only the current one", chunks)
+	}
+}
+
+func TestGetLogFromCompressedChunks(t *testing.T) {
+	setChunkSize(t, 10)
+	l, dir := newTestHugeLogger(t)
+	l.SetCompressRotated(true)
+
+	// two chunks rolled, the third is the current one
+	for i := 0; i < 25; i++ {
+		l.AddLog(LOG_LEVEL_INFO, fmt.Sprintf("log %d", i), "", false)
+	}
+	fls := l.(*logger).logs.(*fileLogStorage)
+	fls.compressing.Wait()
+
+	for fNum := 0; fNum < 2; fNum++ {
+		name := fls.fileNameGeneration(fNum)
+		if _, err := os.Stat(name + "." + CompressedFileExtension); err != nil {
+			t.Fatalf("chunk %d not compressed: %v", fNum, err)
+		}
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Errorf("chunk %d left uncompressed in %s", fNum, dir)
+		}
+	}
+
+	if got := l.GetLog(5).Message(); got != "log 5" {
+		t.Errorf("GetLog(5) = %q, want %q", got, "log 5")
+	}
+	logs, err := l.GetLogsE(3, 17)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, log := range logs {
+		if want := fmt.Sprintf("log %d", i + 3); log.Message() != want {
+			t.Errorf("log %d is %q, want %q", i + 3, log.Message(), want)
+		}
+	}
+}
+
+func TestMetaErrorReported(t *testing.T) {
+	setChunkSize(t, 5)
+	l, _ := newTestHugeLogger(t)
+	fallback := NewLogger(nil)
+	l.SetFallback(fallback)
+
+	fls := l.(*logger).logs.(*fileLogStorage)
+	// a directory in place of the temporary file makes writeMeta fail
+	if err := os.Mkdir(metaFileName(fls.dir, fls.prefix) + ".tmp", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 6; i++ {
+		l.AddLog(LOG_LEVEL_INFO, fmt.Sprintf("log %d", i), "", false)
+	}
+
+	var reported bool
+	for _, log := range fallback.GetLastNLogs(fallback.NLogs()) {
+		if log.Level() == LOG_LEVEL_ERROR && strings.Contains(log.Message(), "storage failed") {
+			reported = true
+		}
+	}
+	if !reported {
+		t.Errorf("the failed rollover was not reported to the fallback: %v", messages(fallback.GetLastNLogs(fallback.NLogs())))
+	}
+	if err := l.Flush(); err == nil {
+		t.Errorf("Flush succeeded without the sidecar file")
+	}
+}
+
+func TestTruncateToLast(t *testing.T) {
+	setChunkSize(t, 10)
+	huge, _ := newTestHugeLogger(t)
+
+	for name, l := range map[string]Logger{ "memory": NewLogger(nil), "huge": huge } {
+		for i := 0; i < 35; i++ {
+			l.AddLog(LOG_LEVEL_INFO, fmt.Sprintf("log %d", i), "", false)
+		}
+
+		if err := l.TruncateToLast(8); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if n := l.NLogs(); n != 8 {
+			t.Errorf("%s: NLogs = %d, want 8", name, n)
+		}
+
+		logs := l.GetLastNLogs(3)
+		if got, want := strings.Join(messages(logs), ","), "log 32,log 33,log 34"; got != want {
+			t.Errorf("%s: GetLastNLogs(3) = %s, want %s", name, got, want)
+		}
+		if log, err := l.GetLogE(0); err != nil || log.Message() != "log 27" {
+			t.Errorf("%s: GetLogE(0) = %q, %v", name, log.Message(), err)
+		}
+
+		l.AddLog(LOG_LEVEL_INFO, "log 35", "", false)
+		if log, err := l.GetLogE(8); err != nil || log.Message() != "log 35" {
+			t.Errorf("%s: GetLogE(8) after truncating = %q, %v", name, log.Message(), err)
+		}
+	}
+}
+
+func TestTruncateToLastPurgesBlobs(t *testing.T) {
+	l := NewLogger(nil)
+
+	var ids []string
+	for i := 0; i < 4; i++ {
+		p, err := l.AddLogWithBlob(LOG_LEVEL_INFO, "blob", []byte{ byte(i) }, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, l.GetLog(p).ID())
+	}
+
+	if err := l.TruncateToLast(1); err != nil {
+		t.Fatal(err)
+	}
+
+	s := l.(*logger).logs.(*memLogStorage)
+	if len(s.blobs) != 1 {
+		t.Errorf("%d blobs kept, want 1", len(s.blobs))
+	}
+	if blob, err := l.GetBlob(ids[3]); err != nil || !bytes.Equal(blob, []byte{ 3 }) {
+		t.Errorf("GetBlob of the kept log = %v, %v", blob, err)
+	}
+}
+
+func TestMissingDir(t *testing.T) {
+	dir := t.TempDir() + "/missing/logs"
+
+	if _, err := NewHugeLoggerWithOptions(HugeLoggerOptions{ Dir: dir, Prefix: "test" }); err == nil {
+		t.Fatal("created a HugeLogger in a missing directory without CreateDir")
+	}
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("the directory was created without CreateDir: %v", err)
+	}
+
+	l, err := NewHugeLoggerWithOptions(HugeLoggerOptions{ Dir: dir, Prefix: "test", CreateDir: true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		t.Fatalf("the directory was not created: %v", err)
+	}
+	l.AddLog(LOG_LEVEL_INFO, "created", "", false)
+	if log, err := l.GetLogE(0); err != nil || log.Message() != "created" {
+		t.Errorf("GetLogE(0) = %q, %v", log.Message(), err)
+	}
+}
+
+func TestOpenHugeLoggerAppends(t *testing.T) {
+	setChunkSize(t, 10)
+	l, dir := newTestHugeLogger(t)
+	for i := 0; i < 25; i++ {
+		l.AddLog(LOG_LEVEL_INFO, fmt.Sprintf("log %d", i), "", false)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenHugeLogger(nil, dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+
+	if n := reopened.NLogs(); n != 25 {
+		t.Fatalf("NLogs after reopening = %d, want 25", n)
+	}
+	// the cache is primed with the tail of the last chunk
+	if got, want := strings.Join(messages(reopened.GetLastNLogs(3)), ","), "log 22,log 23,log 24"; got != want {
+		t.Errorf("GetLastNLogs(3) = %s, want %s", got, want)
+	}
+
+	for i := 25; i < 40; i++ {
+		reopened.AddLog(LOG_LEVEL_INFO, fmt.Sprintf("log %d", i), "", false)
+	}
+	if err := reopened.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, i := range []int{ 3, 24, 25, 39 } {
+		if log, err := reopened.GetLogE(i); err != nil || log.Message() != fmt.Sprintf("log %d", i) {
+			t.Errorf("GetLogE(%d) = %q, %v", i, log.Message(), err)
+		}
+	}
+
+	report, err := ValidateLogDir(dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Sessions) != 1 {
+		t.Fatalf("found %d sessions, want the reopened one only", len(report.Sessions))
+	}
+	if chunks := len(report.Sessions[0].Chunks); chunks != 4 {
+		t.Errorf("the session has %d chunks, want 4", chunks)
+	}
+	if !report.Healthy() {
+		t.Errorf("the session is not healthy: %+v", report)
+	}
+}
+
+func TestConcurrentRollover(t *testing.T) {
+	setChunkSize(t, 10)
+	l, dir := newTestHugeLogger(t)
+	l.SetCompressRotated(true)
+
+	const goroutines, logs = 8, 300
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < logs; i++ {
+				if i % 50 == 0 {
+					batch := []Log{ createLog(LOG_LEVEL_INFO, "batch", "", false) }
+					l.AddLogs(batch, false)
+					continue
+				}
+				l.AddLog(LOG_LEVEL_INFO, fmt.Sprintf("log %d-%d", g, i), "", false)
+			}
+		}(g)
+	}
+
+	// the readers run during the rollovers
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	for r := 0; r < 2; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if n := l.NLogs(); n > 0 {
+					l.GetLastNLogs(15)
+					if _, err := l.GetLogsE(max(0, n - 25), n); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(stop)
+	readers.Wait()
+
+	if n := l.NLogs(); n != goroutines * logs {
+		t.Fatalf("NLogs = %d, want %d", n, goroutines * logs)
+	}
+	all, err := l.GetLogsE(0, l.NLogs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != goroutines * logs {
+		t.Errorf("read %d logs, want %d", len(all), goroutines * logs)
+	}
+
+	if err = l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	report, err := ValidateLogDir(dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Healthy() {
+		t.Errorf("the chunk files are not healthy: %+v", report)
+	}
+	if chunks := len(report.Sessions[0].Chunks); chunks != goroutines * logs / 10 {
+		t.Errorf("found %d chunks, want %d", chunks, goroutines * logs / 10)
+	}
+}
+
+func TestTruncateToLastAfterTTL(t *testing.T) {
+	old := TTLSweepInterval
+	TTLSweepInterval = 5 * time.Millisecond
+	t.Cleanup(func() { TTLSweepInterval = old })
+
+	l := NewLogger(nil)
+	if _, err := l.AddLogWithTTL(LOG_LEVEL_INFO, "short lived", "", false, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	l.AddLog(LOG_LEVEL_INFO, "kept", "", false)
+	l.AddLog(LOG_LEVEL_INFO, "last", "", false)
+
+	// wait for the sweeper to replace the expired log and stop
+	s := l.(*logger).logs.(*memLogStorage)
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.rwm.RLock()
+		sweeping := s.sweeping
+		s.rwm.RUnlock()
+		if !sweeping {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the log with the TTL was never purged")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := l.TruncateToLast(1); err != nil {
+		t.Fatal(err)
+	}
+	if logs := l.GetLastNLogs(1); len(logs) != 1 || logs[0].Message() != "last" {
+		t.Errorf("GetLastNLogs(1) = %v, want the last log", messages(logs))
+	}
+}
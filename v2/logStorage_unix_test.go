@@ -0,0 +1,75 @@
+//go:build unix
+package logger
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+)
+
+// setOpenFilesLimit lowers the soft limit of the open file descriptors
+// to the ones already open plus extra, restoring it after the test
+func setOpenFilesLimit(t *testing.T, extra uint64) {
+	var old syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &old); err != nil {
+		t.Skipf("can't read the limit of the open files: %v", err)
+	}
+
+	// the lowest free descriptor tells about how many are open
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	used := uint64(f.Fd())
+	f.Close()
+
+	limit := old
+	limit.Cur = used + extra
+	if limit.Cur > old.Cur {
+		t.Skipf("the limit of the open files %d is already low", old.Cur)
+	}
+	if err := syscall.Setrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		t.Skipf("can't lower the limit of the open files: %v", err)
+	}
+	t.Cleanup(func() { syscall.Setrlimit(syscall.RLIMIT_NOFILE, &old) })
+}
+
+func TestReadManyChunksWithFewFiles(t *testing.T) {
+	setChunkSize(t, 5)
+	l, _ := newTestHugeLogger(t)
+
+	const n = 1000
+	for i := 0; i < n; i++ {
+		l.AddLog(LOG_LEVEL_INFO, fmt.Sprintf("log %d", i), "", false)
+	}
+
+	setOpenFilesLimit(t, 16)
+
+	logs, err := l.GetLogsE(0, n)
+	if err != nil {
+		t.Fatalf("reading %d chunks: %v", n / 5, err)
+	}
+	for i, log := range logs {
+		if want := fmt.Sprintf("log %d", i); log.Message() != want {
+			t.Fatalf("log %d is %q, want %q", i, log.Message(), want)
+		}
+	}
+
+	var spread []int
+	for i := 0; i < n; i += 3 {
+		spread = append(spread, i)
+	}
+	if logs, err = l.GetSpecificLogsE(spread); err != nil || len(logs) != len(spread) {
+		t.Fatalf("reading %d logs across the chunks: %d logs, %v", len(spread), len(logs), err)
+	}
+
+	count := 0
+	ch, stop := l.GetLogsBuffered(0, n)
+	for logs := range ch {
+		count += len(logs)
+	}
+	if err := stop(); err != nil || count != n {
+		t.Errorf("streamed %d logs with the error %v, want %d", count, err, n)
+	}
+}
@@ -0,0 +1,189 @@
+package logger
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUnknownLevelSeverity(t *testing.T) {
+	custom := RegisterLevel("severity-test", "  Sev  ", "")
+	unknown := LogLevel(-1)
+
+	if custom.Severity() == unknown.Severity() {
+		t.Fatalf("registered and unknown levels share the severity %d", custom.Severity())
+	}
+	if unknown.Severity() != SEVERITY_UNKNOWN {
+		t.Errorf("unknown severity = %d, want SEVERITY_UNKNOWN", unknown.Severity())
+	}
+	if !unknown.AtLeast(custom) || custom.AtLeast(unknown) {
+		t.Errorf("unknown level not ranked above the registered one")
+	}
+
+	SetJSONNumericLevel(true)
+	t.Cleanup(func() { SetJSONNumericLevel(false) })
+
+	for _, level := range []LogLevel{ LOG_LEVEL_DEBUG, LOG_LEVEL_FATAL, custom, unknown } {
+		data, err := json.Marshal(level)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var decoded LogLevel
+		if err = json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded != level {
+			t.Errorf("level %d encoded as %s decoded as %d", level, data, decoded)
+		}
+
+		if (level == custom || level == unknown) && data[0] != '"' {
+			t.Errorf("level %d encoded as %s, want its name", level, data)
+		}
+
+		again, _ := json.Marshal(decoded)
+		if string(again) != string(data) {
+			t.Errorf("level %d encoded as %s, then as %s", level, data, again)
+		}
+	}
+}
+
+func TestIndentExtra(t *testing.T) {
+	for _, extra := range []string{ "a\nb", "a\n\tb\t", "a\n\n", "a \n b \n" } {
+		if got, want := indentExtra(extra), IndentString(extra, 4); got != want {
+			t.Errorf("indentExtra(%q) = %q, want %q like IndentString", extra, got, want)
+		}
+	}
+
+	old := ExtraIndent
+	ExtraIndent = 2
+	t.Cleanup(func() { ExtraIndent = old })
+
+	if got, want := indentExtra("a\nb"), "  a\n  b"; got != want {
+		t.Errorf("indentExtra with ExtraIndent = 2 is %q, want %q", got, want)
+	}
+}
+
+func TestLogIDsUnique(t *testing.T) {
+	l := NewLogger(nil)
+
+	const goroutines, logs = 32, 1000
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < logs; i++ {
+				l.Print(LOG_LEVEL_INFO, "message")
+			}
+		}()
+	}
+	wg.Wait()
+
+	all := l.GetLastNLogs(l.NLogs())
+	if len(all) != goroutines * logs {
+		t.Fatalf("stored %d logs, want %d", len(all), goroutines * logs)
+	}
+
+	ids := make(map[string]bool, len(all))
+	for _, log := range all {
+		if ids[log.ID()] {
+			t.Fatalf("duplicate ID %s", log.ID())
+		}
+		ids[log.ID()] = true
+	}
+}
+
+func TestRelativeTime(t *testing.T) {
+	date := time.Date(2024, 6, 7, 15, 4, 5, 0, time.UTC)
+
+	for _, c := range []struct {
+		age  time.Duration
+		want string
+	}{
+		{ 0, "just now" },
+		{ -time.Second, "just now" },
+		{ 12 * time.Millisecond, "12ms ago" },
+		{ 3500 * time.Millisecond, "3s ago" },
+		{ 5 * time.Minute + 59 * time.Second, "5m ago" },
+		{ 2 * time.Hour, "2h ago" },
+		{ 4 * 24 * time.Hour + time.Hour, "4d ago" },
+	} {
+		if got := FormatRelativeTime(date, date.Add(c.age)); got != c.want {
+			t.Errorf("FormatRelativeTime with age %v = %q, want %q", c.age, got, c.want)
+		}
+	}
+
+	log := Log{ l: newLog(LOG_LEVEL_INFO, "message", "") }
+	log.l.date = date
+	relative := withRelativeTime(log, date.Add(3 * time.Second))
+
+	if got := relative.String(); !strings.HasPrefix(got, "[3s ago] - ") {
+		t.Errorf("String = %q, want the relative time", got)
+	}
+	if got := relative.Colored(); !strings.Contains(got, "[3s ago]") {
+		t.Errorf("Colored = %q, want the relative time", got)
+	}
+	if got := log.String(); strings.Contains(got, "ago") {
+		t.Errorf("the original log shows the relative time: %q", got)
+	}
+
+	var decoded Log
+	if err := json.Unmarshal(relative.JSON(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Date().Equal(date) {
+		t.Errorf("JSON date = %v, want %v", decoded.Date(), date)
+	}
+}
+
+func TestSanitizeMessage(t *testing.T) {
+	l := NewLogger(nil)
+	l.SetSanitizeMessage(true)
+
+	l.AddLog(LOG_LEVEL_INFO, "a\t\tb\r\nc   d\x00e", "keep\t\x00this", false)
+	l.AddLog(LOG_LEVEL_INFO, DARK_RED_COLOR + "  red\t" + DEFAULT_COLOR, "", false)
+
+	logs := l.GetLastNLogs(2)
+	if got, want := logs[0].RawMessage(), "a b\nc de"; got != want {
+		t.Errorf("message = %q, want %q", got, want)
+	}
+	if got, want := logs[0].RawExtra(), "keep\t\x00this"; got != want {
+		t.Errorf("extra = %q, want it untouched", got)
+	}
+	if got, want := logs[1].RawMessage(), DARK_RED_COLOR + " red " + DEFAULT_COLOR; got != want {
+		t.Errorf("colored message = %q, want %q", got, want)
+	}
+
+	l.SetSanitizeMessage(false)
+	l.AddLog(LOG_LEVEL_INFO, "a\t\tb", "", false)
+	if got := l.GetLastNLogs(1)[0].RawMessage(); got != "a\t\tb" {
+		t.Errorf("message = %q with the sanitizing disabled", got)
+	}
+}
+
+func TestGlobalSeqOptIn(t *testing.T) {
+	l := NewLogger(nil)
+	l.Print(LOG_LEVEL_INFO, "without sequence")
+	l.EnableSequence()
+	l.Print(LOG_LEVEL_INFO, "with sequence")
+
+	logs := l.GetLastNLogs(2)
+	if logs[0].Seq() == 0 || logs[1].Seq() <= logs[0].Seq() {
+		t.Fatalf("Seq = %d and %d, want them increasing", logs[0].Seq(), logs[1].Seq())
+	}
+
+	if data := string(logs[0].JSON()); strings.Contains(data, "global_seq") {
+		t.Errorf("JSON without the sequence enabled %s, want no global_seq", data)
+	}
+
+	var decoded Log
+	if err := json.Unmarshal(logs[1].JSON(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Seq() != logs[1].Seq() || decoded.Index() != 1 {
+		t.Errorf("decoded Seq %d and Index %d, want %d and 1", decoded.Seq(), decoded.Index(), logs[1].Seq())
+	}
+}
@@ -1,11 +1,17 @@
 package logger
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
-	"os"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Logger is used by the Router and can be used by the user to
@@ -13,35 +19,146 @@ import (
 // and saved locally in memory, so that they can be retreived
 // programmatically and used (for example to make a view in a website)
 type Logger interface {
+	addBlob(id string, blob []byte) error
+	AddError(level LogLevel, err error)
+	AddHook(fn func(Log)) int
 	AddLog(level LogLevel, message string, extra string, writeOutput bool)
+	AddLogFields(level LogLevel, message string, fields map[string]any, writeOutput bool) int
+	AddLogs(logs []Log, writeOutput bool) []int
+	AddLogWithBlob(level LogLevel, message string, blob []byte, writeOutput bool) (int, error)
+	AddLogWithTTL(level LogLevel, message string, extra string, writeOutput bool, ttl time.Duration) (int, error)
+	AddRedactor(re *regexp.Regexp, replacement string)
+	AsyncOutputDropped() uint64
+	AvgWriteLatency(level LogLevel) time.Duration
+	BufferedScope() (Logger, func())
+	callerEnabled() bool
+	canExpire() bool
 	Clone(out io.Writer, tags ...string) Logger
+	Close() error
 	Debug(a ...any)
+	DisableAsyncOutput()
+	DisableCaller()
+	DisableDeduplication()
 	DisableExtras()
+	DisableSequence()
+	DisableWriteLatency()
+	EnableAsyncOutput(bufferSize int)
+	EnableCaller()
+	EnableDeduplication(window time.Duration, collapse bool)
 	EnableExtras()
+	EnableSequence()
+	EnableWriteLatency()
+	Enabled(level LogLevel) bool
+	Export() ([]Log, LoggerConfig, error)
+	ExportCSV(w io.Writer) error
+	ExportGroupedJSON(w io.Writer, levels ...LogLevel) error
+	ExportJSONL(w io.Writer) (int, error)
+	Fatal(a ...any)
+	Fatalf(format string, a ...any)
+	FilterByLevels(levels ...LogLevel) ([]Log, error)
+	FilterByTags(tags ...string) ([]Log, error)
+	FilterLogs(match func(Log) bool) ([]Log, error)
+	FilterLogsBuffered(match func(Log) bool) (<-chan []Log, func() error)
+	FirstIndex() int
+	Flush() error
+	ForEachLog(start int, end int, fn func(i int, log Log) bool) error
+	GetBlob(id string) ([]byte, error)
 	GetLastNLogs(n int) []Log
 	GetLog(index int) Log
+	GetLogE(index int) (Log, error)
 	GetLogs(start int, end int) []Log
+	GetLogsBuffered(start int, end int) (<-chan []Log, func() error)
+	GetLogsByTimeRange(start time.Time, end time.Time) []Log
+	GetLogsE(start int, end int) ([]Log, error)
+	GetLogsSortedBySeverity(start int, end int) []Log
 	GetSpecificLogs(logs []int) []Log
+	GetSpecificLogsE(logs []int) ([]Log, error)
+	ImportJSONL(r io.Reader) error
+	IndexAt(t time.Time) int
+	LevelCounts() map[LogLevel]int
+	LogsReader(start int, end int) io.ReadCloser
+	MinLevel() LogLevel
+	Name() string
 	newLog(log Log, writeOutput bool) int
 	NLogs() int
 	Out() io.Writer
+	OutputLevel() LogLevel
 	Print(level LogLevel, a ...any)
 	Printf(level LogLevel, format string, a ...any)
+	ReadFrom(r io.Reader) (n int64, err error)
+	removed() int
+	RemoveHook(id int)
+	ReplayToOutput(start int, end int) error
+	ReplayToOutputPaced(start int, end int, pace time.Duration) error
+	RequireFields(keys ...string)
+	ReverseCursor(from int) func(n int) []Log
+	RollingStats(windows ...time.Duration) map[time.Duration]map[LogLevel]int
+	SamplingDropped(level LogLevel) uint64
+	SearchLogs(pattern string) ([]Log, error)
+	SetAsyncOutputPolicy(policy OverflowPolicy)
+	SetCollapseBlankLines(collapse bool)
+	SetColorFromLevel(level LogLevel)
+	SetCompressRotated(compress bool)
+	SetFallback(l Logger)
+	SetFatalExits(exit bool)
+	SetFatalMode(mode FatalMode)
+	SetFormatter(f Formatter)
+	SetInlineExtra(inline bool)
+	SetMaxAge(d time.Duration)
+	SetMaxFileBytes(n int64)
+	SetMinLevel(level LogLevel)
+	SetOutputFilter(filter func(Log) bool)
+	SetOutputLevel(level LogLevel)
+	SetOutputs(writers ...io.Writer)
+	SetRelativeTime(relative bool)
+	SetRetention(maxChunks int)
+	SetSampling(level LogLevel, perSecond int)
+	SetSanitizeMessage(sanitize bool)
+	SetShowTags(show bool)
+	SetSyncInterval(d time.Duration)
+	SetSyncPolicy(policy SyncPolicy)
+	SetVolumeAlert(threshold int, window time.Duration, fn func(count int))
+	setTTL(index int, ttl time.Duration) error
+	Stats() LoggerStats
+	StreamLevelLogs(w io.Writer, levels ...LogLevel) error
+	Subscribe() (<-chan Log, func())
+	SubscribeMatching(tags ...string) (<-chan Log, func())
+	SubscribeMatchingAny(tags ...string) (<-chan Log, func())
+	SubscribeWith(policy OverflowPolicy, tags ...string) *Subscription
+	TagCounts(levels ...LogLevel) map[string]int
+	Tee(secondary Logger) Logger
+	TruncateToLast(n int) error
 	Write(p []byte) (n int, err error)
 }
 
 type logger struct {
-	out         io.Writer
+	output
+	broadcaster
+	tagCounter
+	blankLines
+	partialLine
+	hooks
+	volumeAlert
+	redactors
+	sampler
+	deduplicator
+	fallback
 	logs        logStorage
 	tags        []string
-	disableExtras  bool
+	caller      bool
+	sequence    bool
+	requiredFields []string
+	minLevel    LogLevel
+	fatalMode   FatalMode
+	sanitizeMessage bool
+	name        string
 }
 
 var DefaultLogger Logger
 
 func NewLogger(out io.Writer, tags ...string) Logger {
 	return &logger{
-		out: out,
+		output: output{ out: out },
 		logs: &memLogStorage{
 			v:   make([]Log, 0),
 			rwm: new(sync.RWMutex),
@@ -50,58 +167,312 @@ func NewLogger(out io.Writer, tags ...string) Logger {
 	}
 }
 
+// NewBufferedLogger creates a Logger without output that accumulates its
+// logs in memory, along with a drain function that returns all the logs
+// accumulated so far and empties the Logger. This fits the lifecycle of
+// serverless functions, where logs can be shipped in batch at the end of
+// each invocation. The drain function can be called concurrently with
+// ongoing logging; since the indexes restart from zero after each drain,
+// clones of the Logger should not be used to retreive logs
+func NewBufferedLogger(tags ...string) (Logger, func() []Log) {
+	storage := &memLogStorage{
+		v:   make([]Log, 0),
+		rwm: new(sync.RWMutex),
+	}
+
+	l := &logger{
+		logs: storage,
+		tags: tags,
+	}
+	return l, storage.drain
+}
+
 func NewHugeLogger(out io.Writer, dir string, prefix string, tags ...string) (Logger, error) {
-	fls, err := initFileLogStorage(dir, prefix)
+	fls, err := initFileLogStorage(dir, prefix, false)
 	if err != nil {
 		return nil, err
 	}
 
 	return &logger{
-		out:  out,
+		output: output{ out: out },
 		logs: fls,
 		tags: tags,
 	}, nil
 }
 
-func (l *logger) newLog(log Log, writeOutput bool) int {
-	log.addTags(l.tags...)
-	p := l.logs.addLog(log)
+// OpenHugeLogger is like NewHugeLogger, but instead of starting a new
+// session it reopens the most recent one found in dir with the given prefix
+// (or starts a new one if none is found), so that after a restart the logs
+// already written can be retreived as if they were created by this Logger and
+// the new ones are appended after them. A partially written last log, left by
+// a crash, is discarded. If some chunk files are missing, their logs can't be
+// read (see ErrLogNotFound), but the others can
+func OpenHugeLogger(out io.Writer, dir string, prefix string, tags ...string) (Logger, error) {
+	fls, err := openFileLogStorage(dir, prefix, "", false)
+	if err != nil {
+		return nil, err
+	}
 
-	if l.out == nil || !writeOutput {
-		return p
+	return &logger{
+		output: output{ out: out },
+		logs: fls,
+		tags: tags,
+	}, nil
+}
+
+// HugeLoggerOptions are the options of NewHugeLoggerWithOptions
+type HugeLoggerOptions struct {
+	Out    io.Writer // Out is the output of the Logger, if any
+	Dir    string    // Dir is the directory of the chunk files, relative to the working directory if not absolute
+	Prefix string    // Prefix is the prefix of the chunk files
+	Tags   []string  // Tags are the tags of the Logger
+	// CreateDir creates Dir, with its parents, if it does not exist,
+	// instead of failing like NewHugeLogger
+	CreateDir bool
+	// Reopen reopens the most recent session, like OpenHugeLogger,
+	// instead of starting a new one
+	Reopen bool
+	// Session, if set, is the session reopened with Reopen instead of the
+	// most recent one: it's the prefix shared by its chunk files, like
+	// Prefix followed by the date of the session, as reported by
+	// ValidateLogDir. If it is not found, ErrSessionNotFound is returned
+	Session string
+}
+
+// NewHugeLoggerWithOptions creates a HugeLogger like NewHugeLogger or,
+// with opts.Reopen, like OpenHugeLogger, with the given options
+func NewHugeLoggerWithOptions(opts HugeLoggerOptions) (Logger, error) {
+	var fls *fileLogStorage
+	var err error
+	if opts.Reopen {
+		fls, err = openFileLogStorage(opts.Dir, opts.Prefix, opts.Session, opts.CreateDir)
+	} else {
+		fls, err = initFileLogStorage(opts.Dir, opts.Prefix, opts.CreateDir)
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &logger{
+		output: output{ out: opts.Out },
+		logs: fls,
+		tags: opts.Tags,
+	}, nil
+}
 
-	out := l.out
-	if level := log.Level(); out == os.Stdout && (level == LOG_LEVEL_WARNING || level == LOG_LEVEL_ERROR || level == LOG_LEVEL_FATAL) {
-		out = os.Stderr
+func (l *logger) newLog(log Log, writeOutput bool) int {
+	if !log.l.internal && !levelEnabled(log.Level(), l.minLevel) {
+		return -1
+	}
+	if !l.sample(l, log) {
+		return -1
 	}
 
-	if ToTerminal(l.out) {
-		if log.l.extra != "" && !l.disableExtras {
-			fmt.Fprintln(out, log.l.fullColored())
-		} else {
-			fmt.Fprintln(out, log.l.colored())
+	if l.sanitizeMessage {
+		log.l.message = sanitizeMessage(log.l.message)
+	}
+	l.redact(log)
+	log.addTags(l.tags...)
+	if repeat, collapse := l.dedup(l, log); repeat {
+		if collapse {
+			return -1
 		}
-	} else {
-		if log.l.extra != "" && !l.disableExtras {
-			fmt.Fprintln(out, log.l.full())
-		} else {
-			fmt.Fprintln(out, log.l.String())
+		writeOutput = false
+	}
+	p, err := l.logs.addLog(log, l.sequence)
+	if err != nil {
+		if !l.sendToFallback(log, err) && l.out != nil && writeOutput {
+			l.logToOut(log)
 		}
+		return -1
+	}
+	l.count(log)
+	l.checkVolume()
+	l.dispatch(log)
+	l.runHooks(log)
+
+	if missing := missingFields(log, l.requiredFields); len(missing) > 0 {
+		defer l.newLog(missingFieldsWarning(log, missing), writeOutput)
+	}
+
+	if l.out == nil || !writeOutput {
+		return p
 	}
 
+	l.logToOut(log)
 	return p
 }
 
 // AddLog appends a log without behing printed out
 // on the Logger output or by any parent in cascade
 func (l *logger) AddLog(level LogLevel, message string, extra string, writeOutput bool) {
-	l.newLog(Log{
+	l.newLog(createLog(level, message, extra, l.caller), writeOutput)
+}
+
+// AddLogs appends a copy of each log, in order, keeping its ID, date,
+// message, extra, tags and fields, and returns their indexes, or -1 for
+// the logs that are dropped (see SetMinLevel). It's much faster than
+// adding the logs one by one, since the storage is locked only once and
+// a HugeLogger writes the logs of each chunk file at once, which makes
+// it suited for importing the logs from another system. The logs are
+// handled like the ones created by AddLog: they get the Logger tags,
+// are sent to the subscribers and the hooks and, if writeOutput is
+// true, are written to the output
+func (l *logger) AddLogs(logs []Log, writeOutput bool) []int {
+	res := make([]int, len(logs))
+	batch := make([]Log, 0, len(logs))
+	pos := make([]int, 0, len(logs))
+
+	for i, log := range logs {
+		res[i] = -1
+
+		log = log.copy()
+		if !levelEnabled(log.Level(), l.minLevel) {
+			continue
+		}
+		if l.sanitizeMessage {
+			log.l.message = sanitizeMessage(log.l.message)
+		}
+		l.redact(log)
+		log.addTags(l.tags...)
+
+		batch = append(batch, log)
+		pos = append(pos, i)
+	}
+
+	ps, err := l.logs.addLogs(batch, l.sequence)
+	for j, p := range ps {
+		log := batch[j]
+		res[pos[j]] = p
+
+		l.count(log)
+		l.checkVolume()
+		l.dispatch(log)
+		l.runHooks(log)
+
+		if l.out != nil && writeOutput {
+			l.logToOut(log)
+		}
+		if missing := missingFields(log, l.requiredFields); len(missing) > 0 {
+			l.newLog(missingFieldsWarning(log, missing), writeOutput)
+		}
+	}
+
+	if err != nil {
+		for _, log := range batch[len(ps):] {
+			if !l.sendToFallback(log, err) && l.out != nil && writeOutput {
+				l.logToOut(log)
+			}
+		}
+	}
+
+	return res
+}
+
+// AddLogFields is like AddLog, but the log carries the given structured
+// fields (see Log.Fields) instead of a free-form extra. The fields are kept
+// in the JSON of the log, so they survive the HugeLogger storage, and they
+// are written in place of the extra, one "key=value" per line with the
+// value encoded in JSON. It returns the index of the new log, or -1 if
+// it was dropped
+func (l *logger) AddLogFields(level LogLevel, message string, fields map[string]any, writeOutput bool) int {
+	return addLogFields(l, level, message, fields, writeOutput, l.caller)
+}
+
+// AddError creates a log with the message of err and stores the whole
+// chain of wrapped errors (see errors.Unwrap), from the outermost one,
+// in the "errors" field, with the type and the message of each error;
+// the chain is also written in the extra, indented by depth. Nothing
+// is done if err is nil
+func (l *logger) AddError(level LogLevel, err error) {
+	addError(l, level, err, l.caller)
+}
+
+// createLog creates a new Log, recording its caller if requested
+func createLog(level LogLevel, message string, extra string, caller bool) Log {
+	log := Log{
 		l: newLog(level, message, extra),
-	}, writeOutput)
+	}
+	if caller {
+		log.l.caller = callerLocation()
+	}
+
+	return log
+}
+
+func (l *logger) addBlob(id string, blob []byte) error {
+	return l.logs.addBlob(id, blob)
+}
+
+// AddLogWithBlob creates a log with the given message and stores the blob
+// separately from the logs (in a sidecar file for a HugeLogger), keeping only
+// a reference to it in the log extra. The blob can be retreived with GetBlob
+// using the log ID. It returns the index of the new log
+func (l *logger) AddLogWithBlob(level LogLevel, message string, blob []byte, writeOutput bool) (int, error) {
+	return addLogWithBlob(l, level, message, blob, writeOutput, l.caller)
+}
+
+func addLogWithBlob(l Logger, level LogLevel, message string, blob []byte, writeOutput bool, caller bool) (int, error) {
+	if !l.Enabled(level) {
+		return -1, nil
+	}
+
+	log := createLog(level, message, "", caller)
+	if err := l.addBlob(log.ID(), blob); err != nil {
+		return -1, err
+	}
+
+	log.l.extra = fmt.Sprintf("blob: %s (%d bytes)", log.ID(), len(blob))
+	return l.newLog(log, writeOutput), nil
+}
+
+// ErrTTLUnsupported is returned by AddLogWithTTL when the
+// Logger does not keep its logs in memory
+var ErrTTLUnsupported = errors.New("log TTL is supported only by in-memory Loggers")
+
+func addLogWithTTL(l Logger, level LogLevel, message string, extra string, writeOutput bool, ttl time.Duration, caller bool) (int, error) {
+	if !l.canExpire() {
+		return -1, ErrTTLUnsupported
+	}
+
+	p := l.newLog(createLog(level, message, extra, caller), writeOutput)
+	if p < 0 {
+		return p, nil
+	}
+	return p, l.setTTL(p, ttl)
+}
+
+// AddLogWithTTL is like AddLog, but the log is purged from memory after
+// the given TTL, for example to comply with a data-retention policy.
+// The expired logs are removed periodically (see TTLSweepInterval), but
+// they are never returned after their TTL: GetLogE reports ErrLogExpired
+// and the range getters skip them, so they may return fewer logs than
+// requested. The index of the new log is returned; if the Logger does not
+// keep its logs in memory, no log is created and ErrTTLUnsupported is returned
+func (l *logger) AddLogWithTTL(level LogLevel, message string, extra string, writeOutput bool, ttl time.Duration) (int, error) {
+	return addLogWithTTL(l, level, message, extra, writeOutput, ttl, l.caller)
+}
+
+func (l *logger) canExpire() bool {
+	_, ok := l.logs.(*memLogStorage)
+	return ok
+}
+
+func (l *logger) setTTL(index int, ttl time.Duration) error {
+	return l.logs.setTTL(index, ttl)
+}
+
+// GetBlob returns the blob attached to the log with the given ID
+// (see AddLogWithBlob)
+func (l *logger) GetBlob(id string) ([]byte, error) {
+	return l.logs.getBlob(id)
 }
 
 func print(l Logger, level LogLevel, a ...any) {
+	if !l.Enabled(level) {
+		return
+	}
+
 	var str string
 	first := true
 
@@ -131,6 +502,9 @@ func Print(level LogLevel, a ...any) {
 }
 
 func (l *logger) Printf(level LogLevel, format string, a ...any) {
+	if !l.Enabled(level) {
+		return
+	}
 	l.Print(level, fmt.Sprintf(format, a...))
 }
 
@@ -150,18 +524,94 @@ func Debug(a ...any) {
 	DefaultLogger.Debug(a...)
 }
 
+// Fatal creates a Log with LOG_LEVEL_FATAL severity and then terminates
+// the program with os.Exit(1), unless changed with SetFatalMode
+func (l *logger) Fatal(a ...any) {
+	fatal(l, l.fatalMode, fatalArgs(a...))
+}
+
+// Fatalf is the same as Fatal, but the message is formatted like Printf
+func (l *logger) Fatalf(format string, a ...any) {
+	fatal(l, l.fatalMode, fmt.Sprintf(format, a...))
+}
+
+// SetFatalExits sets whether Fatal and Fatalf terminate the program
+// after logging (the default). When disabled, they only create the
+// FATAL log and the caller is responsible for handling the fatal condition.
+// It's the same as SetFatalMode with FATAL_EXIT or FATAL_CONTINUE
+func (l *logger) SetFatalExits(exit bool) {
+	if exit {
+		l.fatalMode = FATAL_EXIT
+	} else {
+		l.fatalMode = FATAL_CONTINUE
+	}
+}
+
+// SetFatalMode sets what Fatal and Fatalf do after logging: FATAL_EXIT
+// (the default) terminates the program, FATAL_PANIC panics with the
+// message, with the stack trace as the extra of the log, and
+// FATAL_CONTINUE just returns
+func (l *logger) SetFatalMode(mode FatalMode) {
+	l.fatalMode = mode
+}
+
+// SetSanitizeMessage sets whether the message of every new log is cleaned
+// up before being stored: runs of whitespace (like tabs, carriage returns
+// and repeated spaces) are collapsed into a single space, or into a single
+// newline if they contain one, and the other control characters (like
+// null bytes) are removed, keeping the terminal colors. The extra is left
+// untouched. It is disabled by default
+func (l *logger) SetSanitizeMessage(sanitize bool) {
+	l.sanitizeMessage = sanitize
+}
+
 func (l *logger) NLogs() int {
 	return l.logs.nLogs()
 }
 
-func (l *logger) Out() io.Writer {
-	return l.out
+func (l *logger) GetLog(index int) Log {
+	log, err := l.GetLogE(index)
+	if err != nil {
+		panic(err)
+	}
+	return log
 }
 
-func (l *logger) GetLog(index int) Log {
+// GetLogE is the same as GetLog, but it returns an error (such as
+// ErrIndexOutOfRange, ErrLogNotFound or ErrCorruptChunk) instead of
+// panicking when the index is not valid or the log can't be read from
+// the storage, which makes it safe to use with indexes coming from users
+func (l *logger) GetLogE(index int) (Log, error) {
 	return l.logs.getLog(index)
 }
 
+// TruncateToLast removes all but the last n logs, which get the indexes
+// from 0, so that GetLastNLogs and the other getters keep working as if
+// the older logs never existed. For a HugeLogger, the chunk files holding
+// only removed logs are deleted. This is useful to bound the memory or
+// disk used, for example between two runs. The counts of TagCounts and
+// Stats are not changed. The logs of the clones are left untouched, but
+// the ones removed can't be read anymore (see ErrLogNotFound)
+func (l *logger) TruncateToLast(n int) error {
+	if n < 0 {
+		return fmt.Errorf("invalid number of logs to keep: %d", n)
+	}
+	return l.logs.truncate(n)
+}
+
+func (l *logger) removed() int {
+	return l.logs.removed()
+}
+
+// FirstIndex returns the index of the oldest log that can still be read:
+// the logs before it were deleted by the retention of a HugeLogger (see
+// SetRetention and SetMaxAge) and reading them returns ErrLogEvicted. It's
+// always zero for in-memory Loggers. The exports and the searches over
+// the whole history start from it
+func (l *logger) FirstIndex() int {
+	return l.logs.firstIndex()
+}
+
 func (l *logger) GetLastNLogs(n int) []Log {
 	tot := l.logs.nLogs()
 	if n > tot {
@@ -170,37 +620,647 @@ func (l *logger) GetLastNLogs(n int) []Log {
 	return l.GetLogs(tot-n, tot)
 }
 
+// IndexAt returns the index of the first log with a date not before t,
+// which is NLogs if every log is older than t, so that the logs from
+// t onward are GetLogs(IndexAt(t), NLogs()). It uses a binary search,
+// reading only a few logs even for a HugeLogger, so it assumes that the
+// logs are stored in chronological order, which holds unless the system
+// clock goes backwards or the Logger receives copies of older logs
+// (like the secondary Logger of Tee). The logs that can't be
+// read (for example because expired) are considered older than t
+func (l *logger) IndexAt(t time.Time) int {
+	return indexAt(l, t)
+}
+
+// GetLogsByTimeRange returns the logs with a date in [start, end), found
+// with IndexAt, so with the same assumption on the order of the logs
+func (l *logger) GetLogsByTimeRange(start time.Time, end time.Time) []Log {
+	return getLogsByTimeRange(l, start, end)
+}
+
+func getLogsByTimeRange(l Logger, start time.Time, end time.Time) []Log {
+	from, to := l.IndexAt(start), l.IndexAt(end)
+	if to <= from {
+		return []Log{}
+	}
+	return l.GetLogs(from, to)
+}
+
+func indexAt(l Logger, t time.Time) int {
+	first := l.FirstIndex()
+	return first + sort.Search(l.NLogs() - first, func(i int) bool {
+		log, err := l.GetLogE(first + i)
+		return err == nil && !log.Date().Before(t)
+	})
+}
+
+// reverseCursor implements ReverseCursor for any Logger, reading
+// only the logs requested by each call
+func reverseCursor(l Logger, from int) func(n int) []Log {
+	var m sync.Mutex
+	cursor := from
+	if tot := l.NLogs(); cursor > tot {
+		cursor = tot
+	}
+
+	return func(n int) []Log {
+		m.Lock()
+		defer m.Unlock()
+
+		if cursor <= 0 || n <= 0 {
+			return nil
+		}
+
+		start := cursor - n
+		if first := l.FirstIndex(); start < first {
+			start = first
+		}
+		if start >= cursor {
+			return nil
+		}
+
+		logs := l.GetLogs(start, cursor)
+		cursor = start
+
+		res := make([]Log, len(logs))
+		for i, log := range logs {
+			res[len(logs)-1-i] = log
+		}
+		return res
+	}
+}
+
+// ReverseCursor returns a function that, on each call, returns the next n
+// older logs, from the newest to the oldest, starting from the log just
+// before the index from (usually NLogs). This is useful to paginate the logs
+// backwards, like a "load more" in a log viewer; with a HugeLogger only the
+// chunks needed by each call are read. When there are no more logs, the
+// function returns an empty slice
+func (l *logger) ReverseCursor(from int) func(n int) []Log {
+	return reverseCursor(l, from)
+}
+
 func (l *logger) GetLogs(start, end int) []Log {
+	logs, err := l.GetLogsE(start, end)
+	if err != nil {
+		panic(err)
+	}
+	return logs
+}
+
+// GetLogsE is the same as GetLogs, but it returns an error instead of
+// panicking when the range is not valid (see ErrIndexOutOfRange) or
+// the logs can't be read from the storage
+func (l *logger) GetLogsE(start, end int) ([]Log, error) {
 	return l.logs.getLogs(start, end)
 }
 
+// GetLogsSortedBySeverity returns the logs in the range [start, end) sorted
+// from the most severe to the least severe and then by date. The whole range
+// is loaded in memory, so it is not suited for the entire history of a HugeLogger
+func (l *logger) GetLogsSortedBySeverity(start, end int) []Log {
+	return sortedBySeverity(l.GetLogs(start, end))
+}
+
+// ForEachLog calls fn with every log in the range [start, end) and its
+// index, in order, until fn returns false, without loading all the logs
+// in memory: with a HugeLogger the logs are read like GetLogsBuffered,
+// while for an in-memory Logger they are read in place. It's suited for
+// computing aggregates over the whole history. It returns an error if the
+// range is not valid or the logs can't be read. The Logger may be locked
+// while fn runs, so fn must not call any method of the Logger
+func (l *logger) ForEachLog(start, end int, fn func(i int, log Log) bool) error {
+	if mem, ok := l.logs.(*memLogStorage); ok {
+		return mem.forEach(start, end, fn)
+	}
+	return forEachLog(l, start, end, fn)
+}
+
+// GetLogsBuffered streams the logs in the range [start, end) in order,
+// in batches of at most LogChunkSize logs, so that only a few chunks
+// are held in memory at any time; with a HugeLogger, the chunk files are
+// read in parallel (see LogReadConcurrency). The channel is closed when
+// all the logs have been sent. The returned function can be used to stop
+// the stream early and, after the channel is closed, reports the first
+// error encountered while reading the logs
+func (l *logger) GetLogsBuffered(start, end int) (<-chan []Log, func() error) {
+	if end <= start {
+		return streamLogs(0, nil)
+	}
+
+	first := start / LogChunkSize
+	batches := (end-1) / LogChunkSize - first + 1
+
+	return streamLogs(batches, func(batch int) ([]Log, error) {
+		bStart := (first + batch) * LogChunkSize
+		bEnd := bStart + LogChunkSize
+		if bStart < start {
+			bStart = start
+		}
+		if bEnd > end {
+			bEnd = end
+		}
+
+		return l.GetLogsE(bStart, bEnd)
+	})
+}
+
+// ReplayToOutput writes again the logs in the range [start, end) to the
+// Logger output, with the current output settings (colors, extras and
+// min level), as if they were happening now. This is useful to review
+// the logs of a HugeLogger in a familiar format. The logs are streamed from the
+// storage (see GetLogsBuffered) and the first error encountered is returned
+func (l *logger) ReplayToOutput(start, end int) error {
+	return replayToOutput(l, l.logToOut, start, end, 0)
+}
+
+// ReplayToOutputPaced is the same as ReplayToOutput, but it waits pace
+// after writing each log, to replay them at a readable speed
+func (l *logger) ReplayToOutputPaced(start, end int, pace time.Duration) error {
+	return replayToOutput(l, l.logToOut, start, end, pace)
+}
+
+// LogsReader returns a reader that produces the logs in the range
+// [start, end) as NDJSON (one JSON-encoded log per line). The logs are
+// read lazily from the storage one chunk at a time (see GetLogsBuffered),
+// so the reader can be used to stream a large number of logs, for example as
+// an HTTP body. If the reader is not read until io.EOF, it must be closed
+func (l *logger) LogsReader(start, end int) io.ReadCloser {
+	return newLogsReader(l, start, end)
+}
+
+// ExportCSV writes every log of the Logger to w in CSV format, like
+// LogsToCSV, streaming them from the storage like ExportJSONL, so that
+// also the history of a HugeLogger can be exported without loading it
+// in memory
+func (l *logger) ExportCSV(w io.Writer) error {
+	return exportCSV(l, w)
+}
+
+// ExportGroupedJSON writes to w a JSON object grouping the logs by level,
+// in the form {"error": [...], "warning": [...]}, with the groups in the order
+// of the given levels (or every level, from the most severe, if none is given).
+// Levels without logs are omitted. The logs are streamed from the storage,
+// so this can be used with a large number of logs
+func (l *logger) ExportGroupedJSON(w io.Writer, levels ...LogLevel) error {
+	return exportGroupedJSON(l, w, levels...)
+}
+
+// FilterLogs returns the logs of the Logger for which match returns true,
+// in order. The logs are read like ForEachLog, so only the matching ones
+// are kept in memory, also for a HugeLogger with a long history; for the
+// same reason match must not call any method of the Logger. It returns
+// the logs found so far and the first error, if the logs can't be read
+func (l *logger) FilterLogs(match func(Log) bool) ([]Log, error) {
+	return filterLogs(l, match)
+}
+
+// FilterLogsBuffered is like FilterLogs, but streams the matching logs like
+// GetLogsBuffered, in batches with the matching logs of each batch read
+// from the storage (the empty ones are skipped), to handle any number of
+// matches. The returned function stops the stream and reports the first
+// error encountered
+func (l *logger) FilterLogsBuffered(match func(Log) bool) (<-chan []Log, func() error) {
+	return filterLogsBuffered(l, match)
+}
+
+// FilterByTags returns the logs having all the given tags (see
+// Log.Match), reading them like FilterLogs
+func (l *logger) FilterByTags(tags ...string) ([]Log, error) {
+	return filterLogs(l, func(log Log) bool {
+		return log.Match(tags...)
+	})
+}
+
+// FilterByLevels returns the logs having any of the given levels
+// (see Log.LevelMatchAny), reading them like FilterLogs
+func (l *logger) FilterByLevels(levels ...LogLevel) ([]Log, error) {
+	return filterLogs(l, func(log Log) bool {
+		return log.LevelMatchAny(levels...)
+	})
+}
+
+// SearchLogs returns the logs of the Logger that match the regular
+// expression pattern, like LogsSearch. The logs are streamed from the
+// storage (see GetLogsBuffered), so only the matching ones are kept
+// in memory, also for a HugeLogger
+func (l *logger) SearchLogs(pattern string) ([]Log, error) {
+	return searchLogs(l, pattern)
+}
+
+// ExportJSONL writes to w every log of the Logger as newline-delimited
+// JSON (see Log.JSON), streaming them from the storage like
+// StreamLevelLogs, so that also the history of a HugeLogger can be
+// exported without loading it in memory. It returns the number of logs
+// written and the first error encountered. See ImportJSONL
+func (l *logger) ExportJSONL(w io.Writer) (int, error) {
+	return exportJSONL(l, w)
+}
+
+// ImportJSONL adds the logs read from r, one JSON-encoded log per line,
+// like the ones written by ExportJSONL, keeping their IDs, dates, tags
+// and fields (see AddLogs). The logs are not written to the output.
+// Empty lines are skipped; if a line is not a valid log, the logs read
+// before it are added and an error reporting the line is returned. If
+// the storage fails, the import stops with an error reporting the line
+// of the first log that was not stored
+func (l *logger) ImportJSONL(r io.Reader) error {
+	return importJSONL(l, r)
+}
+
+// StreamLevelLogs writes to w the logs with any of the given levels (or
+// every log, if none is given) as newline-delimited JSON, one log per line.
+// Unlike LogsLevelMatch, the logs are streamed from the storage one chunk
+// at a time, so the memory used does not depend on the number of logs,
+// which makes it suitable to export from the archive of a HugeLogger
+func (l *logger) StreamLevelLogs(w io.Writer, levels ...LogLevel) error {
+	return streamLevelLogs(l, w, levels...)
+}
+
 func (l *logger) GetSpecificLogs(logs []int) []Log {
+	res, err := l.GetSpecificLogsE(logs)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// GetSpecificLogsE is the same as GetSpecificLogs, but it returns an error
+// instead of panicking when an index is not valid (see ErrIndexOutOfRange)
+// or the logs can't be read from the storage
+func (l *logger) GetSpecificLogsE(logs []int) ([]Log, error) {
 	return l.logs.getSpecificLogs(logs)
 }
 
-func write(l Logger, p []byte) (n int, err error) {
-	message := string(p)
-	l.Print(LOG_LEVEL_BLANK, message)
-	return len(message), nil
+// partialLine holds the last line written to a Logger through
+// Write, until a following write completes it
+type partialLine struct {
+	pm  sync.Mutex
+	buf []byte
+}
+
+// lines returns the complete lines of p, the first one prefixed by the
+// partial line left by the previous write, and keeps the new partial line
+func (partial *partialLine) lines(p []byte) []string {
+	partial.pm.Lock()
+	defer partial.pm.Unlock()
+
+	var res []string
+	data := append(partial.buf, p...)
+	for {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			break
+		}
+
+		res = append(res, strings.TrimSuffix(string(data[:end]), "\r"))
+		data = data[end+1:]
+	}
+
+	partial.buf = nil
+	if len(data) > 0 {
+		partial.buf = append([]byte(nil), data...)
+	}
+	return res
+}
+
+// take returns and removes the partial line left by the last write
+func (partial *partialLine) take() (string, bool) {
+	partial.pm.Lock()
+	defer partial.pm.Unlock()
+
+	if len(partial.buf) == 0 {
+		return "", false
+	}
+
+	line := string(partial.buf)
+	partial.buf = nil
+	return line, true
+}
+
+// write creates a log for every complete line of p (see partialLine.lines).
+// The logs are created after the partial line is updated and without
+// holding its lock, so that the output or a hook can write to the Logger
+func write(l Logger, p []byte, blank *blankLines, partial *partialLine) (n int, err error) {
+	for _, line := range partial.lines(p) {
+		if !blank.skip(line) {
+			l.AddLog(LOG_LEVEL_BLANK, line, "", true)
+		}
+	}
+	return len(p), nil
 }
 
+// flushPartialLine creates a log with the partial line
+// left by the last write, if any
+func flushPartialLine(l Logger, blank *blankLines, partial *partialLine) {
+	line, ok := partial.take()
+	if !ok {
+		return
+	}
+
+	line = strings.TrimSuffix(line, "\r")
+	if !blank.skip(line) {
+		l.AddLog(LOG_LEVEL_BLANK, line, "", true)
+	}
+}
+
+// Write implements io.Writer: it creates a log with LOG_LEVEL_BLANK for
+// every line written, so that the Logger can be used as the output of a
+// subprocess. A line split across many writes is logged once completed,
+// and a final line without the line feed is logged by Flush and Close.
+// The writes are serialized, so the complete lines written concurrently
+// are never mixed up
 func (l *logger) Write(p []byte) (n int, err error) {
-	return write(l, p)
+	return write(l, p, &l.blankLines, &l.partialLine)
+}
+
+// blankLines keeps track of the empty lines written to a Logger
+// through Write and ReadFrom (see SetCollapseBlankLines)
+type blankLines struct {
+	collapse atomic.Bool
+	last     atomic.Bool
+}
+
+// skip reports whether the message must be dropped because it is
+// empty and the previous one written was empty too
+func (b *blankLines) skip(message string) bool {
+	if !b.collapse.Load() {
+		return false
+	}
+
+	empty := strings.TrimSpace(message) == ""
+	return b.last.Swap(empty) && empty
+}
+
+// SetCollapseBlankLines sets whether consecutive empty lines written to
+// the Logger through Write and ReadFrom (for example by the output of a
+// subprocess) are collapsed into a single blank log. It is disabled by default
+func (b *blankLines) SetCollapseBlankLines(collapse bool) {
+	b.collapse.Store(collapse)
+}
+
+// readFrom reads r until EOF and creates a log for each line,
+// including a final line without the trailing line feed; the
+// first line completes the partial line left by Write, if any
+func readFrom(l Logger, r io.Reader, blank *blankLines, partial *partialLine) (n int64, err error) {
+	prefix, _ := partial.take()
+
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		n += int64(len(line))
+		line, prefix = prefix + line, ""
+
+		if line != "" && !blank.skip(line) {
+			l.AddLog(LOG_LEVEL_BLANK, strings.TrimRight(line, "\r\n"), "", true)
+		}
+
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+}
+
+// ReadFrom implements io.ReaderFrom: like Write, it creates a log for
+// every line read from r, starting by completing the partial line left
+// by a previous Write, but it also logs the final line without the line
+// feed as soon as r reaches EOF. This makes io.Copy from a stream into
+// the Logger efficient
+func (l *logger) ReadFrom(r io.Reader) (n int64, err error) {
+	return readFrom(l, r, &l.blankLines, &l.partialLine)
+}
+
+// EnableDeduplication coalesces the logs that repeat the previous one,
+// with the same level, message and tags, within window from it, like a
+// flapping component logging the same error many times per second: only
+// the first one is written to the output and, when the streak ends because
+// a different log arrives, the same log arrives after the window elapsed
+// or the Logger is flushed (see Flush), a log like "last message repeated
+// 42 times" is created with the same level by the goroutine logging.
+// The repeated logs are stored anyway, unless collapse is true, in which
+// case they are dropped like the ones below the min level. Clones have
+// their own setting; the logs added with AddLogs are never deduplicated
+func (l *logger) EnableDeduplication(window time.Duration, collapse bool) {
+	l.enableDeduplication(l, window, collapse)
+}
+
+// DisableDeduplication stops coalescing the repeated logs
+// (see EnableDeduplication), reporting the current streak, if any
+func (l *logger) DisableDeduplication() {
+	l.disableDeduplication(l)
+}
+
+// Flush makes sure that every log created so far is written, without
+// stopping the Logger like Close: it waits for the buffer of the
+// asynchronous output (see EnableAsyncOutput) to be written to the output
+// and, for a HugeLogger, updates the session sidecar file and syncs the
+// current chunk file to the disk. It's meant to be called before something
+// that could lose the logs, like a crash dump, and it's safe to call while
+// other goroutines are logging, in which case their new logs may or may
+// not be flushed. The pending streak of repeated logs and the logs dropped
+// by the sampling are reported first (see EnableDeduplication and SetSampling)
+func (l *logger) Flush() error {
+	flushPartialLine(l, &l.blankLines, &l.partialLine)
+	l.flushRepeated(l)
+	l.reportDropped(l)
+	l.flushOutput()
+	return l.logs.flush()
+}
+
+// Close releases the resources of the Logger: for a HugeLogger, it
+// updates the session sidecar file and closes the current chunk file.
+// The logs still in the buffer of the asynchronous output are written
+// first (see EnableAsyncOutput). The Logger must not be used after Close
+func (l *logger) Close() error {
+	flushPartialLine(l, &l.blankLines, &l.partialLine)
+	l.flushRepeated(l)
+	l.reportDropped(l)
+	l.DisableAsyncOutput()
+	return l.logs.close()
+}
+
+// levelEnabled reports whether a log with the given level passes
+// the min threshold. Logs with LOG_LEVEL_BLANK always pass
+func levelEnabled(level LogLevel, min LogLevel) bool {
+	return level == LOG_LEVEL_BLANK || level.AtLeast(min)
+}
+
+// Enabled reports whether a log with the given level would be stored by
+// the Logger, rather than dropped because of its severity. It can be used
+// to avoid building expensive log messages that would be discarded anyway:
+//
+//	if l.Enabled(LOG_LEVEL_DEBUG) {
+//		l.Debug(dump())
+//	}
+//
+// Print, Printf and Debug already return before formatting anything
+// when the level is not enabled
+func (l *logger) Enabled(level LogLevel) bool {
+	return levelEnabled(level, l.minLevel)
+}
+
+// Name returns the name the Logger was registered with
+// (see NewNamedLogger), or an empty string
+func (l *logger) Name() string {
+	return l.name
+}
+
+// MinLevel returns the minimum severity a log must have to be kept
+func (l *logger) MinLevel() LogLevel {
+	return l.minLevel
 }
 
-func (l *logger) EnableExtras() {
-	l.disableExtras = false
+// SetMinLevel sets the minimum severity a log must have to be kept by the
+// Logger (see LogLevel.AtLeast). The default is LOG_LEVEL_BLANK, which
+// keeps every log. Less severe logs are dropped as soon as they reach the
+// Logger, whichever method creates them: they are not stored, written or
+// counted, and the methods returning the index of the new log return -1
+func (l *logger) SetMinLevel(level LogLevel) {
+	l.minLevel = level
 }
 
-func (l *logger) DisableExtras() {
-	l.disableExtras = true
+// OutputLevel is the same as MinLevel: there is only one threshold
+// for each Logger, which decides both what is stored and what is written
+func (l *logger) OutputLevel() LogLevel {
+	return l.MinLevel()
+}
+
+// SetOutputLevel is the same as SetMinLevel
+func (l *logger) SetOutputLevel(level LogLevel) {
+	l.SetMinLevel(level)
+}
+
+// SetCompressRotated sets whether a HugeLogger compresses with gzip every
+// chunk file once it is completed, in the background, adding the
+// CompressedFileExtension to its name. The chunk being written is never
+// compressed and the compressed ones are read transparently. If the
+// compression of a file fails, the file is left uncompressed. It has
+// no effect on in-memory Loggers
+func (l *logger) SetCompressRotated(compress bool) {
+	l.logs.setCompressRotated(compress)
+}
+
+// SetMaxFileBytes makes a HugeLogger roll over to a new chunk file as soon
+// as the current one reaches n bytes (so a file exceeds n by at most one
+// log), instead of after LogChunkSize logs, which is useful when the logs
+// vary a lot in length. The number of logs kept in memory is still
+// LogChunkSize. A value of zero restores the rollover by number of logs.
+// It has no effect on in-memory Loggers
+func (l *logger) SetMaxFileBytes(n int64) {
+	l.logs.setMaxFileBytes(n)
+}
+
+// SetRetention makes a HugeLogger keep at most maxChunks chunk files,
+// including the one being written: every time a new chunk file is
+// created, the oldest ones are deleted, together with their logs. Unlike
+// TruncateToLast, the indexes of the logs don't change: NLogs still
+// counts the deleted logs, reading them (directly or through a clone)
+// returns ErrLogEvicted and FirstIndex returns the oldest log available.
+// A value of zero disables it. It has no effect on in-memory Loggers
+func (l *logger) SetRetention(maxChunks int) {
+	l.logs.setRetention(maxChunks)
+}
+
+// SetMaxAge is like SetRetention, but the chunk files are deleted when
+// their last log was written more than d ago. Both can be set, in which
+// case a chunk file is deleted as soon as one of them applies
+func (l *logger) SetMaxAge(d time.Duration) {
+	l.logs.setMaxAge(d)
+}
+
+// SetSyncPolicy sets when a HugeLogger syncs its chunk files to the disk
+// (see os.File.Sync): SYNC_NEVER, the default, syncs them only when the
+// Logger is flushed or closed, SYNC_EVERY_LOG after every log, for the
+// logs that can't be lost, like the audit ones, and SyncInterval(d) at
+// most every d. Each sync waits for the disk, so the more often the files
+// are synced, the fewer logs per second can be stored. With SYNC_EVERY_LOG,
+// a log that can't be synced is not stored, like when the write fails (see
+// SetFallback). It has no effect on in-memory Loggers
+func (l *logger) SetSyncPolicy(policy SyncPolicy) {
+	l.logs.setSyncPolicy(policy)
+}
+
+// SetSyncInterval is the same as SetSyncPolicy(SyncInterval(d))
+func (l *logger) SetSyncInterval(d time.Duration) {
+	l.SetSyncPolicy(SyncInterval(d))
+}
+
+// RequireFields makes the Logger check that every new log has all
+// the given fields (see Log.Fields): when a log is missing some of them,
+// the Logger emits a WARNING log listing the missing keys right after it.
+// The check costs a map lookup for every required key on every log.
+// Calling it without keys disables the check
+func (l *logger) RequireFields(keys ...string) {
+	l.requiredFields = keys
+}
+
+// EnableCaller makes the Logger record the source location of
+// every new log (see Log.Caller). This has a small cost on
+// every log creation
+func (l *logger) EnableCaller() {
+	l.caller = true
+}
+
+func (l *logger) DisableCaller() {
+	l.caller = false
+}
+
+// callerEnabled reports whether the Logger records the caller of
+// the logs, for the writers creating the logs on its behalf
+func (l *logger) callerEnabled() bool {
+	return l.caller
+}
+
+// EnableSequence makes the Logger save in every new log its storage
+// index (see Log.Index), which is then included in the JSON together
+// with the process-wide sequence number (see Log.Seq)
+func (l *logger) EnableSequence() {
+	l.sequence = true
+}
+
+func (l *logger) DisableSequence() {
+	l.sequence = false
+}
+
+// BufferedScope returns a clone of the Logger that keeps its logs in
+// memory, instead of writing them to the output, until the returned
+// function is called: then they are written to the output of the Logger
+// together, in order, as a single block. It's useful to keep the logs
+// of a request together when many are served concurrently. The logs are
+// stored as usual, only the output is deferred; the memory used grows
+// with the logs created between two flushes, after which the scope can
+// be used again
+func (l *logger) BufferedScope() (Logger, func()) {
+	return bufferedScope(l, &l.output)
 }
 
 func (l *logger) Clone(out io.Writer, tags ...string) Logger {
 	return &cloneLogger{
-		out:        out,
-		tags:       tags,
-		disableExtras: l.disableExtras,
-		parent:     l,
+		output: l.clone(out),
+		tags:   tags,
+		parent: l,
+		caller: l.caller,
+	}
+}
+
+// Tee returns a Logger that creates every log in l, like a clone without
+// its own output, and then forwards a copy of it to secondary, without
+// writing it to the secondary output. Unlike a clone, secondary is an
+// independent Logger, with its own storage and settings, so the logs
+// can be kept in two places with different policies. The log is stored in l
+// before being forwarded, synchronously; if secondary panics, the log
+// is still kept in l and the failure is reported by an error log in l
+func (l *logger) Tee(secondary Logger) Logger {
+	return tee(l, l.caller, secondary)
+}
+
+func tee(l Logger, caller bool, secondary Logger) Logger {
+	return &cloneLogger{
+		parent:    l,
+		caller:    caller,
+		secondary: secondary,
 	}
 }
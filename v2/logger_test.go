@@ -0,0 +1,287 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func messages(logs []Log) []string {
+	res := make([]string, 0, len(logs))
+	for _, log := range logs {
+		res = append(res, log.Message())
+	}
+	return res
+}
+
+func TestWriteChunkedLines(t *testing.T) {
+	l := NewLogger(nil)
+	for _, chunk := range []string{ "hel", "lo\nwor", "ld\r\n", "tail" } {
+		if n, err := l.Write([]byte(chunk)); err != nil || n != len(chunk) {
+			t.Fatalf("Write(%q) = %d, %v", chunk, n, err)
+		}
+	}
+
+	got := strings.Join(messages(l.GetLogs(0, l.NLogs())), "|")
+	if got != "hello|world" {
+		t.Errorf("logs after the writes = %q, want %q", got, "hello|world")
+	}
+
+	if err := l.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	got = strings.Join(messages(l.GetLogs(0, l.NLogs())), "|")
+	if got != "hello|world|tail" {
+		t.Errorf("logs after Flush = %q, want %q", got, "hello|world|tail")
+	}
+}
+
+func TestReadFromCompletesPartialLine(t *testing.T) {
+	l := NewLogger(nil)
+	l.Write([]byte("first half, "))
+
+	n, err := l.ReadFrom(strings.NewReader("second half\nlast"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len("second half\nlast")) {
+		t.Errorf("ReadFrom read %d bytes", n)
+	}
+
+	got := strings.Join(messages(l.GetLogs(0, l.NLogs())), "|")
+	if got != "first half, second half|last" {
+		t.Errorf("logs = %q", got)
+	}
+}
+
+func TestWriteFromHook(t *testing.T) {
+	l := NewLogger(nil)
+	l.AddHook(func(log Log) {
+		if log.Message() == "ping" {
+			l.Write([]byte("pong\n"))
+		}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.Write([]byte("ping\n"))
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Write from a hook deadlocked")
+	}
+
+	got := strings.Join(messages(l.GetLogs(0, l.NLogs())), "|")
+	if got != "ping|pong" {
+		t.Errorf("logs = %q, want %q", got, "ping|pong")
+	}
+}
+
+func TestMinLevelDropsLogs(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger(&buf)
+	l.SetMinLevel(LOG_LEVEL_WARNING)
+
+	l.AddLog(LOG_LEVEL_DEBUG, "debug", "", true)
+	l.Print(LOG_LEVEL_INFO, "info")
+	if n := l.NLogs(); n != 0 {
+		t.Errorf("NLogs = %d, want 0", n)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("dropped logs written: %q", buf.String())
+	}
+
+	l.Print(LOG_LEVEL_BLANK, "blank")
+	l.Print(LOG_LEVEL_ERROR, "error")
+	if n := l.NLogs(); n != 2 {
+		t.Errorf("NLogs = %d, want 2", n)
+	}
+
+	l.SetOutputLevel(LOG_LEVEL_ERROR)
+	if l.MinLevel() != LOG_LEVEL_ERROR || l.OutputLevel() != LOG_LEVEL_ERROR {
+		t.Errorf("MinLevel = %v, OutputLevel = %v, want both ERROR", l.MinLevel(), l.OutputLevel())
+	}
+}
+
+func BenchmarkAddLogs(b *testing.B) {
+	logs := make([]Log, 1_000_000)
+	for i := range logs {
+		logs[i] = createLog(LOG_LEVEL_INFO, "imported log", "", false)
+	}
+
+	b.Run("memory", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			NewLogger(nil).AddLogs(logs, false)
+		}
+	})
+
+	b.Run("huge", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			l, err := NewHugeLogger(nil, b.TempDir(), "bench")
+			if err != nil {
+				b.Fatal(err)
+			}
+			l.AddLogs(logs, false)
+			l.Close()
+		}
+	})
+}
+
+// formatCounter counts how many times it is formatted
+type formatCounter struct {
+	n int
+}
+
+func (c *formatCounter) String() string {
+	c.n ++
+	return "formatted"
+}
+
+func BenchmarkDisabledLevel(b *testing.B) {
+	l := NewLogger(nil)
+	l.SetMinLevel(LOG_LEVEL_WARNING)
+	c := new(formatCounter)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Print(LOG_LEVEL_DEBUG, c)
+		l.Printf(LOG_LEVEL_DEBUG, "value: %v", c)
+		l.Debug(c)
+	}
+
+	if c.n != 0 {
+		b.Fatalf("the arguments of a disabled level were formatted %d times", c.n)
+	}
+}
+
+func TestEnabledMatchesStoredLogs(t *testing.T) {
+	l := NewLogger(nil)
+	l.SetMinLevel(LOG_LEVEL_INFO)
+	c := l.Clone(nil)
+	c.SetMinLevel(LOG_LEVEL_WARNING)
+
+	for _, logger := range []Logger{ l, c } {
+		for _, level := range []LogLevel{ LOG_LEVEL_DEBUG, LOG_LEVEL_INFO, LOG_LEVEL_WARNING, LOG_LEVEL_ERROR } {
+			counter := new(formatCounter)
+			n := logger.NLogs()
+			logger.Print(level, counter)
+			logger.Printf(level, "%v", counter)
+
+			stored := logger.NLogs() - n
+			if enabled := logger.Enabled(level); (enabled && stored != 2) || (!enabled && stored != 0) {
+				t.Errorf("%v: Enabled = %v, but %d logs were stored", level, enabled, stored)
+			}
+			if !logger.Enabled(level) && counter.n != 0 {
+				t.Errorf("%v: the arguments were formatted %d times", level, counter.n)
+			}
+		}
+	}
+}
+
+func TestReadersNotBlockedByOutput(t *testing.T) {
+	w := &slowWriter{ delay: 300 * time.Millisecond }
+	l := NewLogger(w)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.Print(LOG_LEVEL_INFO, "slow")
+	}()
+
+	// the log is stored before being written
+	deadline := time.Now().Add(time.Second)
+	for l.NLogs() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	start := time.Now()
+	logs := l.GetLastNLogs(1)
+	if elapsed := time.Since(start); elapsed > 100 * time.Millisecond {
+		t.Errorf("GetLastNLogs blocked for %v by the output", elapsed)
+	}
+	if len(logs) != 1 || logs[0].Message() != "slow" {
+		t.Errorf("GetLastNLogs = %v", messages(logs))
+	}
+
+	select {
+	case <-done:
+		t.Errorf("the write completed before the read, the test proves nothing")
+	default:
+	}
+	<-done
+}
+
+func TestCollapseBlankLines(t *testing.T) {
+	const text = "first\n\n\n\nsecond\n\n\nthird\n"
+
+	for _, collapse := range []bool{ false, true } {
+		l := NewLogger(nil)
+		l.SetCollapseBlankLines(collapse)
+
+		if _, err := l.Write([]byte(text)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := l.ReadFrom(strings.NewReader(text)); err != nil {
+			t.Fatal(err)
+		}
+
+		want := "first,,,,second,,,third"
+		if collapse {
+			want = "first,,second,,third"
+		}
+		want += "," + want
+
+		if got := strings.Join(messages(l.GetLastNLogs(l.NLogs())), ","); got != want {
+			t.Errorf("collapse %v: logs %s, want %s", collapse, got, want)
+		}
+	}
+}
+
+func TestCloneOutputFilter(t *testing.T) {
+	var parentOut, cloneOut strings.Builder
+	l := NewLogger(&parentOut)
+	l.SetFormatter(messageFormatter{})
+
+	c := l.Clone(&cloneOut, "db")
+	c.SetFormatter(messageFormatter{})
+	c.SetOutputFilter(func(log Log) bool {
+		return log.Level().AtLeast(LOG_LEVEL_ERROR)
+	})
+
+	c.Print(LOG_LEVEL_INFO, "query")
+	c.Print(LOG_LEVEL_ERROR, "connection lost")
+	c.Print(LOG_LEVEL_FATAL, "giving up")
+
+	if got, want := cloneOut.String(), "connection lost\ngiving up\n"; got != want {
+		t.Errorf("clone output %q, want %q", got, want)
+	}
+	if got, want := parentOut.String(), "query\nconnection lost\ngiving up\n"; got != want {
+		t.Errorf("parent output %q, want %q", got, want)
+	}
+	if c.NLogs() != 3 || l.NLogs() != 3 {
+		t.Errorf("NLogs = %d (clone) and %d, want 3", c.NLogs(), l.NLogs())
+	}
+	if log := c.GetLog(0); log.Message() != "query" {
+		t.Errorf("clone GetLog(0) = %q, want the filtered log", log.Message())
+	}
+}
+
+func TestReplayToOutputMinLevel(t *testing.T) {
+	var sb strings.Builder
+	l := NewLogger(&sb)
+	l.SetFormatter(messageFormatter{})
+	l.AddLog(LOG_LEVEL_DEBUG, "debug", "", false)
+	l.AddLog(LOG_LEVEL_ERROR, "error", "", false)
+
+	l.SetOutputLevel(LOG_LEVEL_WARNING)
+	if err := l.ReplayToOutput(0, l.NLogs()); err != nil {
+		t.Fatal(err)
+	}
+	if got := sb.String(); got != "error\n" {
+		t.Errorf("replayed %q, want only the error", got)
+	}
+}
@@ -0,0 +1,18 @@
+module github.com/nixpare/logger/v2/loggrpc
+
+go 1.21
+
+require (
+	github.com/nixpare/logger/v2 v2.0.0
+	google.golang.org/grpc v1.64.1
+	google.golang.org/protobuf v1.34.2
+)
+
+require (
+	golang.org/x/net v0.26.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240318140521-94a12d6c2237 // indirect
+)
+
+replace github.com/nixpare/logger/v2 => ../
@@ -0,0 +1,89 @@
+// Package loggrpc streams the logs of a Logger over gRPC with the
+// LogService defined in logservice.proto (see RegisterLogService).
+// It's a separate module, so that the gRPC and protobuf dependencies
+// are only required by the programs using it.
+//
+// The Go code of the service is generated from logservice.proto with
+// protoc-gen-go and protoc-gen-go-grpc
+package loggrpc
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/nixpare/logger/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// RegisterLogService registers on srv a LogService that
+// streams the logs of l, as they are created, to the clients
+// calling Tail. It is backed by the Logger subscriptions, so a slow
+// client misses the new logs instead of blocking the Logger
+func RegisterLogService(srv *grpc.Server, l logger.Logger) {
+	RegisterLogServiceServer(srv, &logService{ l: l })
+}
+
+type logService struct {
+	UnimplementedLogServiceServer
+	l logger.Logger
+}
+
+func (s *logService) Tail(req *TailRequest, stream grpc.ServerStreamingServer[LogMessage]) error {
+	levels, err := parseLevels(req.GetLevels())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	logs, cancel := s.l.SubscribeMatching(req.GetTags()...)
+	defer cancel()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case log, ok := <-logs:
+			if !ok {
+				return nil
+			}
+			if len(levels) > 0 && !log.LevelMatchAny(levels...) {
+				continue
+			}
+
+			if err := stream.Send(newLogMessage(log)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// parseLevels parses the level names of a TailRequest,
+// the same used in the JSON representation of the logs
+func parseLevels(names []string) ([]logger.LogLevel, error) {
+	levels := make([]logger.LogLevel, 0, len(names))
+	for _, name := range names {
+		var level logger.LogLevel
+		if err := level.UnmarshalJSON([]byte(strconv.Quote(name))); err != nil {
+			return nil, err
+		}
+		if level < 0 {
+			return nil, fmt.Errorf("unknown log level %q", name)
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
+
+// newLogMessage converts the log into a LogMessage
+func newLogMessage(log logger.Log) *LogMessage {
+	return &LogMessage{
+		Id:      log.ID(),
+		Level:   log.Level().Name(),
+		Date:    timestamppb.New(log.Date()),
+		Message: log.Message(),
+		Extra:   log.Extra(),
+		Tags:    log.Tags(),
+	}
+}
@@ -0,0 +1,290 @@
+package logger
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// LogWriter can be implemented by an io.Writer used as a Logger output
+// to receive the logs themselves instead of their textual representation:
+// when the output implements it, WriteLog is called in place of writing
+// the formatted (and possibly colored) log
+type LogWriter interface {
+	WriteLog(log Log) error
+}
+
+var (
+	NetWriterBufferSize = 1024 // NetWriterBufferSize is the default maximum number of messages kept by a NetWriter while disconnected
+	NetWriterMinBackoff = 100 * time.Millisecond
+	NetWriterMaxBackoff = 30 * time.Second
+	NetWriterDialTimeout = 5 * time.Second
+)
+
+// ErrNetWriterClosed is returned when writing to a closed NetWriter
+var ErrNetWriterClosed = errors.New("net writer closed")
+
+// NetWriterOption customizes a NetWriter created with NewNetWriter
+type NetWriterOption func(w *netWriter)
+
+// WithNetBufferSize sets the maximum number of messages kept while the
+// connection is down; when the buffer is full the oldest messages are dropped
+func WithNetBufferSize(size int) NetWriterOption {
+	return func(w *netWriter) {
+		w.bufferSize = size
+	}
+}
+
+// WithNetBackoff sets the minimum and maximum time waited between
+// two reconnection attempts. The wait doubles after every failed attempt
+func WithNetBackoff(min, max time.Duration) NetWriterOption {
+	return func(w *netWriter) {
+		w.minBackoff = min
+		w.maxBackoff = max
+	}
+}
+
+// WithNetDialTimeout sets the timeout of every connection attempt
+func WithNetDialTimeout(timeout time.Duration) NetWriterOption {
+	return func(w *netWriter) {
+		w.dialTimeout = timeout
+	}
+}
+
+type netWriter struct {
+	m           sync.Mutex
+	network     string
+	addr        string
+	conn        net.Conn // conn is used only by the sending goroutine
+	closed      bool
+	pending     [][]byte
+	bufferSize  int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	backoff     time.Duration
+	dialTimeout time.Duration
+	wake        chan struct{} // wake signals the sending goroutine that a message was queued
+	stop        chan struct{} // stop is closed by Close
+	done        chan struct{} // done is closed when the sending goroutine returns
+}
+
+// NewNetWriter returns a writer that sends the logs to a remote collector
+// over the given network (see net.Dial), one JSON-encoded log per line (NDJSON),
+// so it can be used as the output of a Logger to ship the logs directly to
+// an aggregator; the logs are never colored. The messages are queued
+// and sent by a dedicated goroutine, so a write never waits for the
+// network. If the connection is lost, the messages are kept in the queue
+// (see WithNetBufferSize) and resent in order as soon as the connection is
+// reestablished, with the reconnection attempts spaced by an exponential
+// backoff; note that a message written just before the connection drops
+// may be accepted by the system and still get lost. An error is returned
+// only if the first connection fails
+func NewNetWriter(network, addr string, opts ...NetWriterOption) (io.WriteCloser, error) {
+	return newNetWriter(network, addr, opts...)
+}
+
+func newNetWriter(network, addr string, opts ...NetWriterOption) (*netWriter, error) {
+	w := &netWriter{
+		network:     network,
+		addr:        addr,
+		bufferSize:  NetWriterBufferSize,
+		minBackoff:  NetWriterMinBackoff,
+		maxBackoff:  NetWriterMaxBackoff,
+		dialTimeout: NetWriterDialTimeout,
+		wake:        make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	conn, err := net.DialTimeout(network, addr, w.dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	w.conn = conn
+
+	go w.run()
+	return w, nil
+}
+
+// WriteLog sends the log as a single line of JSON
+func (w *netWriter) WriteLog(log Log) error {
+	b, err := exportedJSON(log)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// Write queues p to be sent as is to the remote endpoint and returns
+// immediately; if the connection is down, p is kept in the queue
+// and no error is returned
+func (w *netWriter) Write(p []byte) (n int, err error) {
+	msg := make([]byte, len(p))
+	copy(msg, p)
+
+	w.m.Lock()
+	if w.closed {
+		w.m.Unlock()
+		return 0, ErrNetWriterClosed
+	}
+	w.enqueue(msg)
+	w.m.Unlock()
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+	return len(p), nil
+}
+
+// enqueue adds the message to the pending ones, dropping the oldest
+// message if the buffer is full
+func (w *netWriter) enqueue(msg []byte) {
+	if w.bufferSize > 0 && len(w.pending) >= w.bufferSize {
+		w.pending[0] = nil
+		w.pending = w.pending[1:]
+	}
+	w.pending = append(w.pending, msg)
+}
+
+// next removes and returns the first pending message, if any
+func (w *netWriter) next() ([]byte, bool) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if len(w.pending) == 0 {
+		return nil, false
+	}
+	msg := w.pending[0]
+	w.pending[0] = nil
+	w.pending = w.pending[1:]
+	return msg, true
+}
+
+// requeue puts back a message that could not be sent in front of the
+// pending ones, unless the buffer was filled in the meantime, in which
+// case it is the oldest message and it is dropped
+func (w *netWriter) requeue(msg []byte) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if w.bufferSize > 0 && len(w.pending) >= w.bufferSize {
+		return
+	}
+	w.pending = append([][]byte{ msg }, w.pending...)
+}
+
+// run sends the pending messages in order, reconnecting when needed,
+// until Close is called; then it tries a last time to send them
+func (w *netWriter) run() {
+	defer close(w.done)
+
+	for {
+		w.send(false)
+
+		select {
+		case <-w.wake:
+		case <-w.stop:
+			w.send(true)
+			if w.conn != nil {
+				w.conn.Close()
+			}
+			return
+		}
+	}
+}
+
+// send sends the pending messages until the queue is empty. When a
+// connection attempt fails, it waits for the backoff, unless closing,
+// in which case it gives up, dropping the messages left
+func (w *netWriter) send(closing bool) {
+	for {
+		msg, ok := w.next()
+		if !ok {
+			return
+		}
+
+		for {
+			if w.conn == nil && !w.redial() {
+				if closing {
+					return
+				}
+
+				select {
+				case <-time.After(w.backoff):
+					continue
+				case <-w.stop:
+					// Close was called during the backoff: one
+					// more attempt is done by run
+					w.requeue(msg)
+					return
+				}
+			}
+
+			if closing {
+				w.conn.SetWriteDeadline(time.Now().Add(w.dialTimeout))
+			}
+			if _, err := w.conn.Write(msg); err != nil {
+				w.conn.Close()
+				w.conn = nil
+				if closing {
+					return
+				}
+				continue
+			}
+			break
+		}
+	}
+}
+
+// redial tries to reconnect and reports whether the connection is
+// available; after a failure the backoff before the next attempt grows
+func (w *netWriter) redial() bool {
+	conn, err := net.DialTimeout(w.network, w.addr, w.dialTimeout)
+	if err != nil {
+		w.growBackoff()
+		return false
+	}
+
+	w.conn = conn
+	w.backoff = 0
+	return true
+}
+
+func (w *netWriter) growBackoff() {
+	if w.backoff == 0 {
+		w.backoff = w.minBackoff
+	} else {
+		w.backoff *= 2
+	}
+	if w.backoff > w.maxBackoff {
+		w.backoff = w.maxBackoff
+	}
+}
+
+// Close stops accepting new messages, tries a last time to send
+// the pending ones and then closes the connection
+func (w *netWriter) Close() error {
+	w.m.Lock()
+	if w.closed {
+		w.m.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.m.Unlock()
+
+	close(w.stop)
+	<-w.done
+
+	w.m.Lock()
+	w.pending = nil
+	w.m.Unlock()
+	return nil
+}
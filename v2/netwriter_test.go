@@ -0,0 +1,151 @@
+package logger
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+)
+
+func listenTCP(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	return ln
+}
+
+func TestNetWriterSendsInOrder(t *testing.T) {
+	ln := listenTCP(t)
+	received := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		sc := bufio.NewScanner(conn)
+		for sc.Scan() {
+			received <- sc.Text()
+		}
+		close(received)
+	}()
+
+	w, err := NewNetWriter("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range []string{ "one\n", "two\n", "three\n" } {
+		if _, err := w.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("late\n")); err != ErrNetWriterClosed {
+		t.Errorf("Write after Close error = %v, want ErrNetWriterClosed", err)
+	}
+
+	var got []string
+	for msg := range received {
+		got = append(got, msg)
+	}
+	if len(got) != 3 || got[0] != "one" || got[1] != "two" || got[2] != "three" {
+		t.Errorf("received %q", got)
+	}
+}
+
+func TestNetWriterDoesNotBlockWhileDown(t *testing.T) {
+	ln := listenTCP(t)
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			conn.Close()
+		}
+	}()
+
+	w, err := NewNetWriter("tcp", ln.Addr().String(),
+		WithNetBufferSize(10),
+		WithNetBackoff(time.Second, time.Second),
+		WithNetDialTimeout(time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+
+	start := time.Now()
+	for i := 0; i < 1000; i++ {
+		w.Write([]byte("message\n"))
+	}
+	if elapsed := time.Since(start); elapsed > 500 * time.Millisecond {
+		t.Errorf("the writes took %v while the endpoint was down", elapsed)
+	}
+
+	nw := w.(*netWriter)
+	nw.m.Lock()
+	pending := len(nw.pending)
+	nw.m.Unlock()
+	if pending > 10 {
+		t.Errorf("%d messages pending, want at most 10", pending)
+	}
+
+	w.Close()
+}
+
+func TestNetWriterReconnects(t *testing.T) {
+	ln := listenTCP(t)
+	received := make(chan string, 10)
+	go func() {
+		// the first connection is dropped after the first message
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		sc := bufio.NewScanner(conn)
+		if sc.Scan() {
+			received <- sc.Text()
+		}
+		conn.Close()
+
+		conn, err = ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		sc = bufio.NewScanner(conn)
+		for sc.Scan() {
+			received <- sc.Text()
+		}
+	}()
+
+	w, err := NewNetWriter("tcp", ln.Addr().String(), WithNetBackoff(10 * time.Millisecond, 50 * time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	w.Write([]byte("first\n"))
+	if msg := <-received; msg != "first" {
+		t.Fatalf("received %q, want first", msg)
+	}
+
+	// a message written just after the connection drops may get
+	// lost, so keep writing until one goes through the new connection
+	timeout := time.After(5 * time.Second)
+	for {
+		w.Write([]byte("again\n"))
+		select {
+		case msg := <-received:
+			if msg != "again" {
+				t.Fatalf("received %q, want again", msg)
+			}
+			return
+		case <-time.After(50 * time.Millisecond):
+		case <-timeout:
+			t.Fatal("no message received after the reconnection")
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// output holds the settings shared by every Logger implementation
+// that decide if and how a log is written to the Logger io.Writer
+type output struct {
+	out           io.Writer
+	disableExtras bool
+	colorFrom     LogLevel
+	inlineExtra   bool
+	formatter     Formatter
+	am            sync.Mutex // am serializes the changes to the asynchronous output
+	async         atomic.Pointer[asyncOutput]
+	asyncPolicy   OverflowPolicy
+	asyncDropped  atomic.Uint64
+	latency       atomic.Pointer[writeLatency]
+	filter        func(Log) bool
+	showTags      bool
+	relativeTime  bool
+}
+
+// clone returns a new output writing to out that inherits
+// all the settings of o
+func (o *output) clone(out io.Writer) output {
+	return output{
+		out:           out,
+		disableExtras: o.disableExtras,
+		colorFrom:     o.colorFrom,
+		inlineExtra:   o.inlineExtra,
+		formatter:     o.formatter,
+		asyncPolicy:   o.asyncPolicy,
+		showTags:      o.showTags,
+		relativeTime:  o.relativeTime,
+	}
+}
+
+// wantLog reports whether the log passes the filter, if any. The
+// severity is checked before, when the log reaches the Logger
+func (o *output) wantLog(log Log) bool {
+	return o.filter == nil || o.filter(log)
+}
+
+func (o *output) logToOut(log Log) {
+	if o.out == nil || !o.wantLog(log) {
+		return
+	}
+
+	// a log sent while the asynchronous output is
+	// being disabled is written synchronously
+	if a := o.async.Load(); a != nil && a.send(asyncEntry{ log: log }) {
+		return
+	}
+	o.writeOut(log)
+}
+
+// writeOut writes the log to the output, or to each
+// of the outputs set with SetOutputs
+func (o *output) writeOut(log Log) {
+	wl := o.latency.Load()
+	if wl == nil {
+		for _, w := range o.writers() {
+			o.writeTo(w, log)
+		}
+		return
+	}
+
+	start := time.Now()
+	for _, w := range o.writers() {
+		o.writeTo(w, log)
+	}
+	wl.observe(log.Level(), time.Since(start))
+}
+
+// writers returns the destinations of the output
+func (o *output) writers() []io.Writer {
+	if m, ok := o.out.(*multiOutput); ok {
+		return m.writers
+	}
+	return []io.Writer{ o.out }
+}
+
+// writeTo writes the log to w, one of the destinations of the output
+func (o *output) writeTo(w io.Writer, log Log) {
+	if lw, ok := w.(LogWriter); ok {
+		lw.WriteLog(log)
+		return
+	}
+
+	out := w
+	if level := log.Level(); out == os.Stdout && (level == LOG_LEVEL_WARNING || level == LOG_LEVEL_ERROR || level == LOG_LEVEL_FATAL) {
+		out = os.Stderr
+	}
+
+	fmt.Fprintln(out, o.format(w, log))
+}
+
+// format returns the log as it is written to w, which
+// is colored only if w is a terminal
+func (o *output) format(w io.Writer, log Log) string {
+	if o.disableExtras {
+		log = withoutExtra(log)
+	} else if o.inlineExtra {
+		log = inlineExtra(log)
+	}
+
+	formatter := o.formatter
+	if formatter == nil {
+		formatter = DefaultFormatter{}
+	}
+	if _, ok := formatter.(DefaultFormatter); ok && o.showTags && len(log.tags) > 0 {
+		log = withTags(log)
+	}
+	if o.relativeTime {
+		log = withRelativeTime(log, time.Now())
+	}
+
+	colored := ToTerminal(w) && levelEnabled(log.Level(), o.colorFrom)
+	return formatter.Format(log, colored)
+}
+
+// writeBlock writes the logs to the output with a single write, so
+// that they are not interleaved with the ones written concurrently.
+// To keep the block together, it is always written to the output,
+// even the warnings and the errors when the output is os.Stdout. With
+// the asynchronous output, the block is queued like a single log, so
+// that it keeps its place among the logs already queued
+func (o *output) writeBlock(logs []Log) {
+	if o.out == nil || len(logs) == 0 {
+		return
+	}
+
+	if a := o.async.Load(); a != nil && a.send(asyncEntry{ block: logs }) {
+		return
+	}
+	o.writeBlockOut(logs)
+}
+
+// writeBlockOut writes the block of logs to the output (see writeBlock)
+func (o *output) writeBlockOut(logs []Log) {
+	for _, w := range o.writers() {
+		if lw, ok := w.(LogWriter); ok {
+			for _, log := range logs {
+				if o.wantLog(log) {
+					lw.WriteLog(log)
+				}
+			}
+			continue
+		}
+
+		var sb strings.Builder
+		for _, log := range logs {
+			if o.wantLog(log) {
+				sb.WriteString(o.format(w, log))
+				sb.WriteByte('\n')
+			}
+		}
+		if sb.Len() > 0 {
+			io.WriteString(w, sb.String())
+		}
+	}
+}
+
+// flushOutput waits for the logs in the buffer of
+// the asynchronous output, if enabled, to be written
+func (o *output) flushOutput() {
+	if a := o.async.Load(); a != nil {
+		a.flush()
+	}
+}
+
+// multiOutput is the output of a Logger with many
+// destinations (see SetOutputs)
+type multiOutput struct {
+	writers []io.Writer
+}
+
+// Write writes p to every destination, like io.MultiWriter
+func (m *multiOutput) Write(p []byte) (n int, err error) {
+	return io.MultiWriter(m.writers...).Write(p)
+}
+
+// withoutExtra returns a copy of the log without its extra and
+// its fields, or the log itself if it has neither
+func withoutExtra(log Log) Log {
+	if log.l.extra == "" && len(log.l.fields) == 0 {
+		return log
+	}
+
+	l := *log.l
+	l.extra = ""
+	l.fields = nil
+	return Log{ l: &l, tags: log.tags }
+}
+
+// withTags returns a copy of the log with its tags
+// before the message, as "[a] [b] message"
+func withTags(log Log) Log {
+	l := *log.l
+	l.message = renderTags(log.tags) + " " + l.message
+	return Log{ l: &l, tags: log.tags }
+}
+
+// withRelativeTime returns a copy of the log that shows
+// its age at now instead of its date
+func withRelativeTime(log Log, now time.Time) Log {
+	l := *log.l
+	l.shownDate = FormatRelativeTime(l.date, now)
+	return Log{ l: &l, tags: log.tags }
+}
+
+// InlineExtraMaxLength is the maximum length of an extra that is
+// written on the same line of the message (see SetInlineExtra)
+var InlineExtraMaxLength = 80
+
+// inlineExtra returns a copy of the log with its extra appended to
+// the message as "message (extra)", if the extra is a single line
+// not longer than InlineExtraMaxLength, or the log itself otherwise
+func inlineExtra(log Log) Log {
+	extra := log.l.cleanExtra()
+	if extra == "" || strings.Contains(extra, "\n") || len(extra) > InlineExtraMaxLength {
+		return log
+	}
+
+	l := *log.l
+	l.message = fmt.Sprintf("%s (%s)", l.message, strings.TrimSpace(l.extra))
+	l.extra = ""
+	return Log{ l: &l, tags: log.tags }
+}
+
+func (o *output) Out() io.Writer {
+	return o.out
+}
+
+// SetOutputs replaces the output of the Logger with the given writers:
+// every log is written to each of them, formatted separately, so that it
+// is colored only on the terminals and the warnings and the errors are
+// redirected to os.Stderr only for os.Stdout. A LogWriter receives the
+// logs themselves, as usual. With no writers the logs are not written
+// anywhere; the clones created before keep their output
+func (o *output) SetOutputs(writers ...io.Writer) {
+	var ws []io.Writer
+	for _, w := range writers {
+		if w != nil {
+			ws = append(ws, w)
+		}
+	}
+
+	switch len(ws) {
+	case 0:
+		o.out = nil
+	case 1:
+		o.out = ws[0]
+	default:
+		o.out = &multiOutput{ writers: ws }
+	}
+}
+
+func (o *output) EnableExtras() {
+	o.disableExtras = false
+}
+
+func (o *output) DisableExtras() {
+	o.disableExtras = true
+}
+
+// SetShowTags sets whether the tags of the logs are written before
+// their message, as "[a] [b] message", each one colored on a terminal
+// with the color set with SetTagColor. It only applies to the
+// DefaultFormatter, since the other ones write the tags on their own
+func (o *output) SetShowTags(show bool) {
+	o.showTags = show
+}
+
+// SetRelativeTime sets whether the logs are written to the output with
+// their age at the moment they are written, like "3s ago" (see
+// FormatRelativeTime), instead of their date, which is handy while
+// debugging interactively. It applies to the formatters that use the text
+// of the log, like the DefaultFormatter; the stored logs and their JSON
+// keep the date. It is disabled by default and inherited by the clones
+func (o *output) SetRelativeTime(relative bool) {
+	o.relativeTime = relative
+}
+
+// SetOutputFilter sets a function that decides which logs are written
+// to the output, in addition to the min level: only the logs for
+// which filter returns true are written. The logs are stored anyway, so
+// NLogs and the indexes are not affected. A nil filter writes every log.
+// Every Logger has its own filter, which is not inherited by the clones:
+// for example a clone writing to the terminal can show only the errors
+// while the Logger it was cloned from writes everything to a file
+func (o *output) SetOutputFilter(filter func(Log) bool) {
+	o.filter = filter
+}
+
+// SetColorFromLevel sets the minimum severity a log must have to be
+// colored when the output is a terminal; less severe logs are written
+// plainly. The default is LOG_LEVEL_BLANK, which colors every log
+func (o *output) SetColorFromLevel(level LogLevel) {
+	o.colorFrom = level
+}
+
+// SetFormatter sets how the logs are written to the output, both on
+// terminals and on any other io.Writer; a nil Formatter restores the
+// DefaultFormatter. It is not used when the output is a LogWriter
+func (o *output) SetFormatter(f Formatter) {
+	o.formatter = f
+}
+
+// SetInlineExtra sets whether a short extra (a single line not longer than
+// InlineExtraMaxLength) is written on the same line of the message, as
+// "message (extra)", instead of on an indented new line. Longer extras
+// are always written in the usual way
+func (o *output) SetInlineExtra(inline bool) {
+	o.inlineExtra = inline
+}
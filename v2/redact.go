@@ -0,0 +1,113 @@
+package logger
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// redactors holds the patterns registered with AddRedactor
+type redactors struct {
+	rdm  sync.RWMutex
+	list []redactor
+}
+
+type redactor struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+// AddRedactor makes the Logger replace every match of re in the message
+// and in the extra of the new logs with replacement, before they are
+// stored, written to the output or sent anywhere else, for example to
+// mask the tokens and the emails that must never hit the disk. Inside
+// replacement, $1 and ${name} are expanded like in regexp.ReplaceAllString.
+// The patterns are matched against the text without the terminal colors,
+// which are kept around the replaced text. The redactors are applied in
+// the order they were added; the ones of a clone are applied before the
+// ones of the Logger it was cloned from, which also apply to the logs of
+// the clone. The fields of the logs are not redacted
+func (r *redactors) AddRedactor(re *regexp.Regexp, replacement string) {
+	r.rdm.Lock()
+	defer r.rdm.Unlock()
+
+	r.list = append(r.list, redactor{ re: re, replacement: replacement })
+}
+
+// redact applies the redactors to the message and the extra of the log
+func (r *redactors) redact(log Log) {
+	r.rdm.RLock()
+	list := r.list
+	r.rdm.RUnlock()
+
+	for _, rd := range list {
+		log.l.message = rd.apply(log.l.message)
+		log.l.extra = rd.apply(log.l.extra)
+	}
+}
+
+func (rd redactor) apply(s string) string {
+	if s == "" {
+		return s
+	}
+	if !strings.Contains(s, "\x1b[") {
+		return rd.re.ReplaceAllString(s, rd.replacement)
+	}
+
+	// match the text without the colors, keeping
+	// the position of each byte in s
+	var plain strings.Builder
+	pos := make([]int, 0, len(s) + 1)
+	for i := 0; i < len(s); {
+		if n := colorCodeLen(s[i:]); n > 0 {
+			i += n
+			continue
+		}
+		plain.WriteByte(s[i])
+		pos = append(pos, i)
+		i++
+	}
+	pos = append(pos, len(s))
+	text := plain.String()
+
+	matches := rd.re.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return s
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := pos[m[0]], pos[m[0]]
+		if m[1] > m[0] {
+			end = pos[m[1] - 1] + 1
+		}
+
+		sb.WriteString(s[last:start])
+		sb.Write(rd.re.ExpandString(nil, rd.replacement, text, m))
+		// the colors inside the match are kept after the replacement
+		for i := start; i < end; {
+			if n := colorCodeLen(s[i:end]); n > 0 {
+				sb.WriteString(s[i:i+n])
+				i += n
+				continue
+			}
+			i++
+		}
+		last = end
+	}
+	sb.WriteString(s[last:])
+
+	return sb.String()
+}
+
+// colorCodeLen returns the length of the terminal color
+// at the start of s, or zero if there is none
+func colorCodeLen(s string) int {
+	for _, c := range all_terminal_colors {
+		if strings.HasPrefix(s, c) {
+			return len(c)
+		}
+	}
+	return 0
+}
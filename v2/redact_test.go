@@ -0,0 +1,47 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var cardNumber = regexp.MustCompile(`\b(?:\d{4}[ -]?){3}\d{4}\b`)
+
+func TestRedactCardNumbers(t *testing.T) {
+	l, dir := newTestHugeLogger(t)
+	l.AddRedactor(cardNumber, "****")
+
+	l.AddLog(LOG_LEVEL_INFO, "paid with 4111 1111 1111 1111", "card=4111-1111-1111-1111", false)
+	l.Clone(nil, "checkout").AddLog(LOG_LEVEL_INFO, "refunded " + DARK_RED_COLOR + "5500000000000004" + DEFAULT_COLOR, "", false)
+
+	logs := l.GetLastNLogs(2)
+	if got := logs[0].Message(); got != "paid with ****" {
+		t.Errorf("Message = %q, want the card number masked", got)
+	}
+	if got := logs[0].Extra(); got != "card=****" {
+		t.Errorf("Extra = %q, want the card number masked", got)
+	}
+	if got, want := logs[1].RawMessage(), "refunded " + DARK_RED_COLOR + "****" + DEFAULT_COLOR; got != want {
+		t.Errorf("clone RawMessage = %q, want %q", got, want)
+	}
+
+	if err := l.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*." + LogFileExtension))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no chunk file found: %v", err)
+	}
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cardNumber.Match(b) || !strings.Contains(string(b), "****") {
+			t.Errorf("%s holds an unmasked card number:\n%s", file, b)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package logger
+
+import (
+	"io"
+	"sort"
+	"sync"
+)
+
+// registry holds the Loggers created with NewNamedLogger
+var registry = struct {
+	m       sync.RWMutex
+	loggers map[string]Logger
+}{
+	loggers: make(map[string]Logger),
+}
+
+// NewNamedLogger creates a Logger like NewLogger and registers it with the
+// given name, so that it can be retreived anywhere with GetNamedLogger, for
+// example to change the configuration of a subsystem Logger from an admin
+// endpoint. If another Logger was registered with the same name, it is replaced
+func NewNamedLogger(name string, out io.Writer, tags ...string) Logger {
+	l := NewLogger(out, tags...).(*logger)
+	l.name = name
+
+	registerLogger(name, l)
+	return l
+}
+
+// registerLogger registers l with the given name,
+// replacing the Logger registered with it, if any
+func registerLogger(name string, l Logger) {
+	registry.m.Lock()
+	defer registry.m.Unlock()
+
+	registry.loggers[name] = l
+}
+
+// GetNamedLogger returns the Logger registered with the given name
+// (see NewNamedLogger), if any
+func GetNamedLogger(name string) (Logger, bool) {
+	registry.m.RLock()
+	defer registry.m.RUnlock()
+
+	l, ok := registry.loggers[name]
+	return l, ok
+}
+
+// ListLoggers returns the names of all the registered Loggers,
+// in alphabetical order
+func ListLoggers() []string {
+	registry.m.RLock()
+	defer registry.m.RUnlock()
+
+	names := make([]string, 0, len(registry.loggers))
+	for name := range registry.loggers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,146 @@
+package logger
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// sampler drops the logs of the levels that exceed their rate
+// (see SetSampling) with a token bucket for each level
+type sampler struct {
+	sm        sync.Mutex
+	buckets   map[LogLevel]*bucket
+	firstDrop time.Time // firstDrop is zero when there are no drops to report
+}
+
+type bucket struct {
+	rate    int
+	tokens  float64
+	last    time.Time
+	dropped uint64
+	pending int // pending is the number of drops not yet reported
+}
+
+// SetSampling caps the logs of the given level to perSecond per second,
+// allowing bursts of up to perSecond logs: the excess ones are dropped
+// before being stored, like the ones below the min level, so that a
+// flood of logs can't overwhelm the output and the storage. The drops
+// are counted (see SamplingDropped) and reported with a warning like
+// "dropped 42 info logs in last second", created by the first log that
+// arrives a second after the first drop or by Flush, so that it's always
+// created by the goroutine logging. The logs of a clone are sampled by
+// its own settings and then by the ones of the Logger it was cloned from;
+// the logs added with AddLogs are never sampled. A perSecond less than or
+// equal to zero removes the cap
+func (s *sampler) SetSampling(level LogLevel, perSecond int) {
+	s.sm.Lock()
+	defer s.sm.Unlock()
+
+	if perSecond <= 0 {
+		delete(s.buckets, level)
+		return
+	}
+
+	if s.buckets == nil {
+		s.buckets = make(map[LogLevel]*bucket)
+	}
+	b := s.buckets[level]
+	if b == nil {
+		b = &bucket{ tokens: float64(perSecond), last: time.Now() }
+		s.buckets[level] = b
+	}
+	b.rate = perSecond
+}
+
+// SamplingDropped returns how many logs of the given level
+// were dropped by the sampling (see SetSampling)
+func (s *sampler) SamplingDropped(level LogLevel) uint64 {
+	s.sm.Lock()
+	defer s.sm.Unlock()
+
+	if b := s.buckets[level]; b != nil {
+		return b.dropped
+	}
+	return 0
+}
+
+// sample reports whether the log can be stored; if not, the drop is
+// counted and reported with a warning created in l by the first log
+// sampled a second later. The drops of the last second are reported
+// before the log is stored
+func (s *sampler) sample(l Logger, log Log) bool {
+	if log.l.internal {
+		return true
+	}
+
+	s.sm.Lock()
+	now := time.Now()
+	due := !s.firstDrop.IsZero() && now.Sub(s.firstDrop) >= time.Second
+	keep := s.take(log.Level(), now)
+	s.sm.Unlock()
+
+	if due {
+		s.reportDropped(l)
+	}
+	return keep
+}
+
+// take consumes a token of the bucket of the level, if any,
+// and reports whether the log can be stored
+func (s *sampler) take(level LogLevel, now time.Time) bool {
+	b := s.buckets[level]
+	if b == nil {
+		return true
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * float64(b.rate)
+	if b.tokens > float64(b.rate) {
+		b.tokens = float64(b.rate)
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens --
+		return true
+	}
+
+	b.dropped ++
+	b.pending ++
+	if s.firstDrop.IsZero() {
+		s.firstDrop = now
+	}
+	return false
+}
+
+// reportDropped creates a warning in l for each level
+// with some drops not yet reported
+func (s *sampler) reportDropped(l Logger) {
+	s.sm.Lock()
+	pending := make(map[LogLevel]int)
+	for level, b := range s.buckets {
+		if b.pending > 0 {
+			pending[level] = b.pending
+			b.pending = 0
+		}
+	}
+	s.firstDrop = time.Time{}
+	s.sm.Unlock()
+
+	levels := make([]LogLevel, 0, len(pending))
+	for level := range pending {
+		levels = append(levels, level)
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		return levels[i].Severity() < levels[j].Severity()
+	})
+
+	for _, level := range levels {
+		l.newLog(newInternalLog(
+			LOG_LEVEL_WARNING,
+			fmt.Sprintf("dropped %d %s logs in last second", pending[level], level.Name()),
+			"",
+		), true)
+	}
+}
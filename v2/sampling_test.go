@@ -0,0 +1,65 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSamplingFlood(t *testing.T) {
+	l := NewLogger(nil)
+	l.SetSampling(LOG_LEVEL_INFO, 10)
+
+	const flood = 1000
+	for i := 0; i < flood; i++ {
+		l.Print(LOG_LEVEL_INFO, "flood")
+	}
+	l.Print(LOG_LEVEL_ERROR, "not sampled")
+
+	stored := l.LevelCounts()[LOG_LEVEL_INFO]
+	dropped := l.SamplingDropped(LOG_LEVEL_INFO)
+	if stored < 10 || stored > 20 {
+		t.Errorf("stored %d info logs, want about 10", stored)
+	}
+	if stored + int(dropped) != flood {
+		t.Errorf("stored %d and dropped %d info logs, want %d in total", stored, dropped, flood)
+	}
+	if n := l.LevelCounts()[LOG_LEVEL_ERROR]; n != 1 {
+		t.Errorf("stored %d error logs, want 1", n)
+	}
+
+	want := fmt.Sprintf("dropped %d info logs in last second", dropped)
+	l.Flush()
+	last := l.GetLastNLogs(1)[0]
+	if last.Message() != want {
+		t.Fatalf("no %q report after Flush, last logs: %v", want, messages(l.GetLastNLogs(3)))
+	}
+	if last.Level() != LOG_LEVEL_WARNING {
+		t.Errorf("drop report level = %v, want WARNING", last.Level())
+	}
+}
+
+// TestSamplingCloneRace checks that the drops of a clone are reported
+// by the goroutine logging a second later: run it with -race
+func TestSamplingCloneRace(t *testing.T) {
+	l := NewLogger(nil)
+	c := l.Clone(nil)
+	c.SetSampling(LOG_LEVEL_INFO, 1)
+
+	for i := 0; i < 10; i++ {
+		c.Print(LOG_LEVEL_INFO, "flood")
+	}
+	if got := messages(c.GetLastNLogs(c.NLogs())); len(got) != 1 {
+		t.Fatalf("the clone has the logs %q, want only the first one", got)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	c.Print(LOG_LEVEL_INFO, "after")
+
+	got := messages(c.GetLastNLogs(c.NLogs()))
+	want := []string{ "flood", "dropped 9 info logs in last second", "after" }
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("the clone has the logs %q, want %q", got, want)
+	}
+}
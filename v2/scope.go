@@ -0,0 +1,46 @@
+package logger
+
+import (
+	"strings"
+	"sync"
+)
+
+// scopeBuffer is the output of a Logger created by BufferedScope:
+// it holds the logs until they are flushed
+type scopeBuffer struct {
+	m    sync.Mutex
+	logs []Log
+}
+
+func (b *scopeBuffer) WriteLog(log Log) error {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	b.logs = append(b.logs, log)
+	return nil
+}
+
+// Write buffers p as a log with LOG_LEVEL_BLANK
+func (b *scopeBuffer) Write(p []byte) (n int, err error) {
+	b.WriteLog(createLog(LOG_LEVEL_BLANK, strings.TrimSuffix(string(p), "\n"), "", false))
+	return len(p), nil
+}
+
+// drain returns the buffered logs and empties the buffer
+func (b *scopeBuffer) drain() []Log {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	logs := b.logs
+	b.logs = nil
+	return logs
+}
+
+func bufferedScope(l Logger, o *output) (Logger, func()) {
+	buf := &scopeBuffer{}
+	scope := l.Clone(buf)
+
+	return scope, func() {
+		o.writeBlock(buf.drain())
+	}
+}
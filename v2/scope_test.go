@@ -0,0 +1,60 @@
+package logger
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowWriter records what is written to it, taking some time for each write
+type slowWriter struct {
+	m     sync.Mutex
+	delay time.Duration
+	sb    strings.Builder
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(w.delay)
+
+	w.m.Lock()
+	defer w.m.Unlock()
+	return w.sb.Write(p)
+}
+
+func (w *slowWriter) String() string {
+	w.m.Lock()
+	defer w.m.Unlock()
+	return w.sb.String()
+}
+
+// messageFormatter writes only the message of the logs
+type messageFormatter struct{}
+
+func (messageFormatter) Format(l Log, colored bool) string {
+	return l.Message()
+}
+
+func TestBufferedScopeKeepsOrderWithAsyncOutput(t *testing.T) {
+	w := &slowWriter{ delay: 5 * time.Millisecond }
+	l := NewLogger(w)
+	l.SetFormatter(messageFormatter{})
+	l.EnableAsyncOutput(64)
+
+	for i := 0; i < 5; i++ {
+		l.Print(LOG_LEVEL_INFO, "before")
+	}
+
+	scope, flush := l.BufferedScope()
+	scope.Print(LOG_LEVEL_INFO, "scope 1")
+	scope.Print(LOG_LEVEL_INFO, "scope 2")
+	flush()
+
+	l.Print(LOG_LEVEL_INFO, "after")
+	l.DisableAsyncOutput()
+
+	want := strings.Repeat("before\n", 5) + "scope 1\nscope 2\nafter\n"
+	if got := w.String(); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,107 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// slogHandler is a slog.Handler that creates the logs in a Logger
+type slogHandler struct {
+	l      Logger
+	attrs  []string // attrs holds the "key: value" lines added with WithAttrs
+	prefix string   // prefix is prepended to the keys of the attributes, one "group." for each group
+}
+
+// NewSlogHandler returns a slog.Handler that creates every record in the
+// Logger l, so that the libraries using log/slog can log into it. The
+// slog levels are mapped to LOG_LEVEL_DEBUG, LOG_LEVEL_INFO, LOG_LEVEL_WARNING
+// and LOG_LEVEL_ERROR, the record message becomes the log message and the
+// attributes are written in the extra, one per line in the form "key: value"
+// (which can be parsed with ParseKeyValueExtra), with the keys of the
+// attributes in a group prefixed by "group."
+func NewSlogHandler(l Logger) slog.Handler {
+	return &slogHandler{ l: l }
+}
+
+// slogLevel maps a slog level to the corresponding LogLevel
+func slogLevel(level slog.Level) LogLevel {
+	switch {
+	case level < slog.LevelInfo:
+		return LOG_LEVEL_DEBUG
+	case level < slog.LevelWarn:
+		return LOG_LEVEL_INFO
+	case level < slog.LevelError:
+		return LOG_LEVEL_WARNING
+	default:
+		return LOG_LEVEL_ERROR
+	}
+}
+
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.l.Enabled(slogLevel(level))
+}
+
+func (h *slogHandler) Handle(_ context.Context, r slog.Record) error {
+	lines := make([]string, 0, len(h.attrs) + r.NumAttrs())
+	lines = append(lines, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		lines = appendSlogAttr(lines, h.prefix, a)
+		return true
+	})
+
+	log := createLog(slogLevel(r.Level), r.Message, strings.Join(lines, "\n"), false)
+	if !r.Time.IsZero() {
+		log.l.date = r.Time
+	}
+
+	h.l.newLog(log, true)
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	h2 := *h
+	h2.attrs = make([]string, 0, len(h.attrs) + len(attrs))
+	h2.attrs = append(h2.attrs, h.attrs...)
+	for _, a := range attrs {
+		h2.attrs = appendSlogAttr(h2.attrs, h.prefix, a)
+	}
+	return &h2
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	return &h2
+}
+
+// appendSlogAttr appends to lines the attribute in the form "key: value",
+// with the key prefixed by prefix, flattening the groups
+func appendSlogAttr(lines []string, prefix string, a slog.Attr) []string {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return lines
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if a.Key != "" {
+			groupPrefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			lines = appendSlogAttr(lines, groupPrefix, ga)
+		}
+		return lines
+	}
+
+	return append(lines, fmt.Sprintf("%s%s: %v", prefix, a.Key, a.Value.Any()))
+}
@@ -0,0 +1,45 @@
+package logger
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestSlogHandlerRoundTrip(t *testing.T) {
+	l := NewLogger(nil)
+	sl := slog.New(NewSlogHandler(l)).With("service", "api").WithGroup("req")
+
+	sl.Warn("slow request", "path", "/users", slog.Group("timing", "ms", 1500))
+	sl.Debug("details")
+	slog.New(NewSlogHandler(l)).Error("failed")
+
+	logs := l.GetLastNLogs(3)
+	if len(logs) != 3 {
+		t.Fatalf("stored %d logs, want 3", len(logs))
+	}
+
+	if logs[0].Level() != LOG_LEVEL_WARNING || logs[0].Message() != "slow request" {
+		t.Errorf("log 0 = %v %q", logs[0].Level(), logs[0].Message())
+	}
+	if got, want := logs[0].Extra(), "service: api\nreq.path: /users\nreq.timing.ms: 1500"; got != want {
+		t.Errorf("extra = %q, want %q", got, want)
+	}
+	if logs[1].Level() != LOG_LEVEL_DEBUG || logs[1].Extra() != "service: api" {
+		t.Errorf("log 1 = %v %q %q", logs[1].Level(), logs[1].Message(), logs[1].Extra())
+	}
+	if logs[2].Level() != LOG_LEVEL_ERROR || logs[2].Extra() != "" {
+		t.Errorf("log 2 = %v %q %q", logs[2].Level(), logs[2].Message(), logs[2].Extra())
+	}
+}
+
+func TestSlogHandlerEnabled(t *testing.T) {
+	l := NewLogger(nil)
+	l.SetMinLevel(LOG_LEVEL_WARNING)
+	sl := slog.New(NewSlogHandler(l))
+
+	sl.Info("dropped")
+	sl.Error("kept")
+	if n := l.NLogs(); n != 1 {
+		t.Errorf("NLogs = %d, want 1", n)
+	}
+}
@@ -0,0 +1,97 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// exportState reads every log of l, failing if one of them can't
+// be read, since the following ones would not keep their index
+func exportState(l Logger) ([]Log, LoggerConfig, error) {
+	cfg := l.Stats().Config
+
+	end := l.NLogs()
+	logs := make([]Log, 0, end)
+	err := l.ForEachLog(0, end, func(i int, log Log) bool {
+		if i != len(logs) {
+			return false
+		}
+		logs = append(logs, log)
+		return true
+	})
+	if err != nil {
+		return nil, cfg, err
+	}
+
+	// the logs that can't be read are skipped by ForEachLog
+	if missing := len(logs); missing < end {
+		_, err := l.GetLogE(missing)
+		if err == nil {
+			err = ErrLogNotFound
+		}
+		return nil, cfg, fmt.Errorf("log %d can't be exported: %w", missing, err)
+	}
+	return logs, cfg, nil
+}
+
+// NewLoggerFromState creates a Logger like NewLogger that already holds
+// the given logs, with the same indexes, and the given configuration, so
+// that the state returned by Export can be handed off to a new Logger,
+// for example when reloading the configuration of a service without
+// losing the history. The logs are stored as they are: the min level and
+// the tags of the configuration only apply to the new ones; they are
+// counted by LevelCounts, TagCounts and Stats, but they are not sent to
+// the subscribers and the hooks nor written to out. Every field of cfg is
+// applied except Clone and Output (out is used instead) and FatalExits,
+// which is superseded by FatalMode; if Name is set, the new Logger is
+// registered with that name, replacing the old one (see NewNamedLogger).
+// The blobs and the TTLs of the logs are not transferred
+func NewLoggerFromState(out io.Writer, logs []Log, cfg LoggerConfig) Logger {
+	l := &logger{
+		output: output{
+			out:           out,
+			disableExtras: !cfg.Extras,
+			colorFrom:     cfg.ColorFromLevel,
+			inlineExtra:   cfg.InlineExtra,
+		},
+		logs: &memLogStorage{
+			v:   append(make([]Log, 0, len(logs)), logs...),
+			rwm: new(sync.RWMutex),
+		},
+		tags:            append([]string(nil), cfg.Tags...),
+		caller:          cfg.Caller,
+		sequence:        cfg.Sequence,
+		requiredFields:  append([]string(nil), cfg.RequiredFields...),
+		minLevel:        cfg.MinLevel,
+		fatalMode:       cfg.FatalMode,
+		sanitizeMessage: cfg.SanitizeMessage,
+		name:            cfg.Name,
+	}
+
+	for _, log := range logs {
+		l.count(log)
+	}
+
+	if l.name != "" {
+		registerLogger(l.name, l)
+	}
+	return l
+}
+
+// Export returns a copy of every log of the Logger, in order, and its
+// configuration, which can be passed to NewLoggerFromState to move the
+// Logger state to a new instance, where the logs keep their indexes. For
+// this reason, if a log can't be read (for example because expired, see
+// AddLogWithTTL, or deleted by the retention, see SetRetention), nothing
+// is exported and the error is returned. With a HugeLogger every log is
+// read into memory, so it's meant for the in-memory Loggers
+func (l *logger) Export() ([]Log, LoggerConfig, error) {
+	return exportState(l)
+}
+
+// Export is like the one of the Logger it was cloned from, but returns only
+// the logs created through the clone and the configuration of the clone
+func (l *cloneLogger) Export() ([]Log, LoggerConfig, error) {
+	return exportState(l)
+}
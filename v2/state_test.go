@@ -0,0 +1,55 @@
+package logger
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExportToNewLoggerKeepsIndexes(t *testing.T) {
+	l := NewLogger(nil, "service")
+	l.SetMinLevel(LOG_LEVEL_INFO)
+	for _, msg := range []string{ "zero", "one", "two" } {
+		l.AddLog(LOG_LEVEL_INFO, msg, "", false)
+	}
+
+	logs, cfg, err := l.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Name = "state-test"
+	restored := NewLoggerFromState(nil, logs, cfg)
+
+	// the configuration must not be shared with the new Logger
+	cfg.Tags[0] = "changed"
+
+	if n := restored.NLogs(); n != 3 {
+		t.Fatalf("NLogs = %d, want 3", n)
+	}
+	if msg := restored.GetLog(2).Message(); msg != "two" {
+		t.Errorf("GetLog(2) = %q, want two", msg)
+	}
+	if tags := restored.Stats().Config.Tags; len(tags) != 1 || tags[0] != "service" {
+		t.Errorf("tags = %v, want [service]", tags)
+	}
+	if restored.MinLevel() != LOG_LEVEL_INFO {
+		t.Errorf("MinLevel = %v, want info", restored.MinLevel())
+	}
+	if named, ok := GetNamedLogger("state-test"); !ok || named != restored {
+		t.Error("the new Logger was not registered with its name")
+	}
+}
+
+func TestExportFailsOnExpiredLogs(t *testing.T) {
+	l := NewLogger(nil)
+	l.AddLog(LOG_LEVEL_INFO, "kept", "", false)
+	if _, err := l.AddLogWithTTL(LOG_LEVEL_INFO, "expiring", "", false, time.Nanosecond); err != nil {
+		t.Fatal(err)
+	}
+	l.AddLog(LOG_LEVEL_INFO, "after", "", false)
+	time.Sleep(time.Millisecond)
+
+	if _, _, err := l.Export(); !errors.Is(err, ErrLogExpired) {
+		t.Errorf("Export error = %v, want ErrLogExpired", err)
+	}
+}
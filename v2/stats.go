@@ -0,0 +1,175 @@
+package logger
+
+import "time"
+
+// LoggerStats is a snapshot of the configuration and of the runtime
+// statistics of a Logger (see Logger.Stats), which can be encoded
+// in JSON, for example to be served by a diagnostics endpoint
+type LoggerStats struct {
+	Config        LoggerConfig             `json:"config"`
+	NLogs         int                      `json:"n_logs"`
+	LevelCounts   map[string]int           `json:"level_counts"`
+	Subscriptions int                      `json:"subscriptions"`
+	Storage       StorageStats             `json:"storage"`
+	WriteLatency  map[string]time.Duration `json:"write_latency,omitempty"`
+}
+
+// LoggerConfig is the configuration of a Logger, as reported by Stats
+type LoggerConfig struct {
+	Name            string    `json:"name,omitempty"`
+	Clone           bool      `json:"clone"`
+	Tags            []string  `json:"tags"`
+	MinLevel        LogLevel  `json:"min_level"`
+	Output          bool      `json:"output"`
+	Extras          bool      `json:"extras"`
+	InlineExtra     bool      `json:"inline_extra"`
+	ColorFromLevel  LogLevel  `json:"color_from_level"`
+	Caller          bool      `json:"caller"`
+	Sequence        bool      `json:"sequence"`
+	RequiredFields  []string  `json:"required_fields,omitempty"`
+	FatalExits      bool      `json:"fatal_exits"`
+	FatalMode       FatalMode `json:"fatal_mode"`
+	SanitizeMessage bool      `json:"sanitize_message"`
+}
+
+// StorageStats describes the storage of a Logger, as reported by Stats.
+// Kind is "memory" or "file"; the other fields are set only if they
+// apply to the storage
+type StorageStats struct {
+	Kind    string `json:"kind"`
+	Dir     string `json:"dir,omitempty"`
+	Prefix  string `json:"prefix,omitempty"`
+	Chunks  int    `json:"chunks,omitempty"`
+	Bytes   int64  `json:"bytes,omitempty"`
+	Expired int    `json:"expired,omitempty"`
+}
+
+// config fills the part of the configuration held by the output
+func (o *output) config(c LoggerConfig) LoggerConfig {
+	c.Output = o.out != nil
+	c.Extras = !o.disableExtras
+	c.InlineExtra = o.inlineExtra
+	c.ColorFromLevel = o.colorFrom
+	return c
+}
+
+func (b *broadcaster) nSubscriptions() int {
+	b.m.RLock()
+	defer b.m.RUnlock()
+	return len(b.subs)
+}
+
+// Stats returns the configuration of the Logger together with its
+// runtime statistics, such as the number of logs for each level and the
+// size of the storage. It only reads counters, so it is cheap enough to
+// be called often, for example by a /debug/logger endpoint
+func (l *logger) Stats() LoggerStats {
+	return LoggerStats{
+		Config: l.config(LoggerConfig{
+			Name:            l.name,
+			Tags:            append([]string(nil), l.tags...),
+			MinLevel:        l.minLevel,
+			Caller:          l.caller,
+			Sequence:        l.sequence,
+			RequiredFields:  append([]string(nil), l.requiredFields...),
+			FatalExits:      l.fatalMode == FATAL_EXIT,
+			FatalMode:       l.fatalMode,
+			SanitizeMessage: l.sanitizeMessage,
+		}),
+		NLogs:         l.NLogs(),
+		LevelCounts:   l.levelCounts(),
+		Subscriptions: l.nSubscriptions(),
+		Storage:       l.logs.stats(),
+		WriteLatency:  l.writeLatencies(),
+	}
+}
+
+// Stats returns the configuration and the statistics of the clone;
+// the storage is the one of the Logger it was cloned from
+func (l *cloneLogger) Stats() LoggerStats {
+	parent := l.parent.Stats()
+
+	return LoggerStats{
+		Config: l.config(LoggerConfig{
+			Clone:           true,
+			Tags:            append([]string(nil), l.tags...),
+			MinLevel:        l.minLevel,
+			Caller:          l.caller,
+			Sequence:        parent.Config.Sequence,
+			RequiredFields:  append([]string(nil), l.requiredFields...),
+			FatalExits:      l.fatalMode == FATAL_EXIT,
+			FatalMode:       l.fatalMode,
+			SanitizeMessage: l.sanitizeMessage,
+		}),
+		NLogs:         l.NLogs(),
+		LevelCounts:   l.levelCounts(),
+		Subscriptions: l.nSubscriptions(),
+		Storage:       parent.Storage,
+		WriteLatency:  l.writeLatencies(),
+	}
+}
+
+// RollingStats returns, for each of the given windows (like one, five
+// and fifteen minutes), the number of logs of each level created in that
+// last period of time. It is a snapshot taken at the moment of the call:
+// it reads only the logs of the largest window, found with IndexAt, so
+// it is cheap also for a HugeLogger, as long as the windows are short.
+// The logs that can't be read (for example because expired) are not counted
+func (l *logger) RollingStats(windows ...time.Duration) map[time.Duration]map[LogLevel]int {
+	return rollingStats(l, windows)
+}
+
+// RollingStats is like the one of the Logger it was cloned
+// from, but counts only the logs created through the clone
+func (l *cloneLogger) RollingStats(windows ...time.Duration) map[time.Duration]map[LogLevel]int {
+	return rollingStats(l, windows)
+}
+
+func rollingStats(l Logger, windows []time.Duration) map[time.Duration]map[LogLevel]int {
+	now := time.Now()
+	res := make(map[time.Duration]map[LogLevel]int, len(windows))
+
+	var largest time.Duration
+	for _, w := range windows {
+		res[w] = make(map[LogLevel]int)
+		if w > largest {
+			largest = w
+		}
+	}
+	if len(windows) == 0 {
+		return res
+	}
+
+	count := func(log Log) {
+		for w, counts := range res {
+			if !log.Date().Before(now.Add(-w)) {
+				counts[log.Level()] ++
+			}
+		}
+	}
+
+	end := l.NLogs()
+	for start := l.IndexAt(now.Add(-largest)); start < end; start += LogChunkSize {
+		batchEnd := start + LogChunkSize
+		if batchEnd > end {
+			batchEnd = end
+		}
+
+		logs, err := l.GetLogsE(start, batchEnd)
+		if err != nil {
+			// read one by one to skip only the logs that can't be read
+			for i := start; i < batchEnd; i++ {
+				if log, err := l.GetLogE(i); err == nil {
+					count(log)
+				}
+			}
+			continue
+		}
+
+		for _, log := range logs {
+			count(log)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,21 @@
+package logger
+
+import "testing"
+
+func TestStatsReturnsCopies(t *testing.T) {
+	l := NewLogger(nil, "a", "b")
+	l.RequireFields("user")
+	c := l.Clone(nil, "c")
+	c.RequireFields("request")
+
+	for _, lg := range []Logger{ l, c } {
+		cfg := lg.Stats().Config
+		cfg.Tags[0] = "changed"
+		cfg.RequiredFields[0] = "changed"
+
+		cfg = lg.Stats().Config
+		if cfg.Tags[0] == "changed" || cfg.RequiredFields[0] == "changed" {
+			t.Errorf("Stats exposes the slices of the Logger: %+v", cfg)
+		}
+	}
+}
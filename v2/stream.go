@@ -0,0 +1,431 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// batchResult is the outcome of the read of a single batch of logs
+type batchResult struct {
+	logs []Log
+	err  error
+}
+
+// streamLogs reads the batches of logs with the provided fetch function,
+// using up to LogReadConcurrency goroutines, and sends them in order on
+// the returned channel. At most LogReadConcurrency batches are held in memory
+// at any time. The returned function stops the stream (if not already
+// completed) and reports the first error encountered, if any
+func streamLogs(batches int, fetch func(batch int) ([]Log, error)) (<-chan []Log, func() error) {
+	ch := make(chan []Log)
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	var streamErr error
+
+	workers := LogReadConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > batches {
+		workers = batches
+	}
+
+	results := make([]chan batchResult, batches)
+	for i := range results {
+		results[i] = make(chan batchResult, 1)
+	}
+	sem := make(chan struct{}, workers)
+
+	var once sync.Once
+	cancel := func() { once.Do(func() { close(done) }) }
+
+	go func() {
+		for i := 0; i < batches; i++ {
+			select {
+			case sem <- struct{}{}:
+			case <-done:
+				return
+			}
+
+			go func(i int) {
+				var logs []Log
+				err := PanicToErr(func() (err error) {
+					logs, err = fetch(i)
+					return
+				})
+				results[i] <- batchResult{logs: logs, err: err}
+			}(i)
+		}
+	}()
+
+	go func() {
+		defer close(finished)
+		defer close(ch)
+		// the producer is released as soon as the stream ends,
+		// even if the caller never calls stop
+		defer cancel()
+
+		for i := 0; i < batches; i++ {
+			var res batchResult
+			select {
+			case res = <-results[i]:
+			case <-done:
+				return
+			}
+
+			if res.err != nil {
+				streamErr = res.err
+				return
+			}
+
+			select {
+			case ch <- res.logs:
+			case <-done:
+				return
+			}
+			<-sem
+		}
+	}()
+
+	stop := func() error {
+		cancel()
+		<-finished
+		return streamErr
+	}
+
+	return ch, stop
+}
+
+func exportJSONL(l Logger, w io.Writer) (int, error) {
+	var n int
+
+	ch, stop := l.GetLogsBuffered(l.FirstIndex(), l.NLogs())
+	for logs := range ch {
+		for _, log := range logs {
+			b, err := exportedJSON(log)
+			if err == nil {
+				_, err = w.Write(append(b, '\n'))
+			}
+			if err != nil {
+				stop()
+				return n, err
+			}
+			n++
+		}
+	}
+	return n, stop()
+}
+
+func importJSONL(l Logger, r io.Reader) error {
+	br := bufio.NewReader(r)
+	batch := make([]Log, 0, LogChunkSize)
+	lines := make([]int, 0, LogChunkSize)
+
+	// add stores the logs read so far: a log that is not stored
+	// even if its level is enabled was rejected by the storage
+	add := func() error {
+		for i, p := range l.AddLogs(batch, false) {
+			if p < 0 && l.Enabled(batch[i].Level()) {
+				return fmt.Errorf("line %d: the log could not be stored", lines[i])
+			}
+		}
+		batch, lines = batch[:0], lines[:0]
+		return nil
+	}
+
+	for lineN := 1; ; lineN++ {
+		line, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var log Log
+			if err := unmarshalExportedJSON(line, &log); err != nil {
+				if addErr := add(); addErr != nil {
+					return addErr
+				}
+				return fmt.Errorf("line %d: %w", lineN, err)
+			}
+
+			batch = append(batch, log)
+			lines = append(lines, lineN)
+			if len(batch) == LogChunkSize {
+				if err := add(); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err == io.EOF {
+			return add()
+		}
+		if err != nil {
+			if addErr := add(); addErr != nil {
+				return addErr
+			}
+			return err
+		}
+	}
+}
+
+func forEachLog(l Logger, start, end int, fn func(i int, log Log) bool) error {
+	if err := checkRange(start, end, l.NLogs()); err != nil {
+		return err
+	}
+
+	ch, stop := l.GetLogsBuffered(start, end)
+	i := start
+	for logs := range ch {
+		for _, log := range logs {
+			if !fn(i, log) {
+				return stop()
+			}
+			i++
+		}
+	}
+	return stop()
+}
+
+// replayToOutput streams the logs of l in the range [start, end)
+// to logToOut, waiting pace after each one; the logs below the
+// current min level are skipped
+func replayToOutput(l Logger, logToOut func(log Log), start, end int, pace time.Duration) error {
+	ch, stop := l.GetLogsBuffered(start, end)
+	for logs := range ch {
+		for _, log := range logs {
+			if !l.Enabled(log.Level()) {
+				continue
+			}
+			logToOut(log)
+			if pace > 0 {
+				time.Sleep(pace)
+			}
+		}
+	}
+
+	return stop()
+}
+
+// logsReader is an io.Reader that encodes as NDJSON the logs
+// received from a stream, one batch at a time
+type logsReader struct {
+	ch   <-chan []Log
+	stop func() error
+	buf  bytes.Buffer
+	err  error
+}
+
+func newLogsReader(l Logger, start, end int) *logsReader {
+	ch, stop := l.GetLogsBuffered(start, end)
+	return &logsReader{ ch: ch, stop: stop }
+}
+
+func (r *logsReader) Read(p []byte) (n int, err error) {
+	for r.buf.Len() == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		logs, ok := <-r.ch
+		if !ok {
+			r.err = r.stop()
+			if r.err == nil {
+				r.err = io.EOF
+			}
+			continue
+		}
+
+		for _, l := range logs {
+			b, err := exportedJSON(l)
+			if err != nil {
+				r.stop()
+				r.err = err
+				break
+			}
+			r.buf.Write(b)
+			r.buf.WriteByte('\n')
+		}
+	}
+
+	return r.buf.Read(p)
+}
+
+// Close stops the underlying stream, releasing its resources
+// if the reader was not read until the end
+func (r *logsReader) Close() error {
+	err := r.stop()
+	if r.err == nil {
+		r.err = io.ErrClosedPipe
+	}
+	return err
+}
+
+// exportGroupedJSON writes the logs of l as a JSON object with a key for each
+// level, holding the array of logs with that level; it does one pass over the
+// logs for each level, so that only a few chunks are in memory at any time
+func exportGroupedJSON(l Logger, w io.Writer, levels ...LogLevel) error {
+	if len(levels) == 0 {
+		levels = []LogLevel{
+			LOG_LEVEL_FATAL, LOG_LEVEL_ERROR, LOG_LEVEL_WARNING,
+			LOG_LEVEL_INFO, LOG_LEVEL_DEBUG, LOG_LEVEL_BLANK,
+		}
+	}
+
+	if _, err := io.WriteString(w, "{"); err != nil {
+		return err
+	}
+
+	n := l.NLogs()
+	firstGroup := true
+	done := make(map[LogLevel]bool)
+
+	for _, level := range levels {
+		if done[level] {
+			continue
+		}
+		done[level] = true
+
+		// the key is always the name, even when the levels
+		// are encoded as numbers (see SetJSONNumericLevel)
+		key, err := json.Marshal(level.Name())
+		if err != nil {
+			return err
+		}
+
+		empty := true
+		ch, stop := l.GetLogsBuffered(l.FirstIndex(), n)
+		for logs := range ch {
+			for _, log := range LogsLevelMatch(logs, level) {
+				var prefix []byte
+				switch {
+				case empty && firstGroup:
+					prefix = append(key, ':', '[')
+				case empty:
+					prefix = append([]byte{','}, append(key, ':', '[')...)
+				default:
+					prefix = []byte{','}
+				}
+				empty, firstGroup = false, false
+
+				b, err := exportedJSON(log)
+				if err == nil {
+					_, err = w.Write(append(prefix, b...))
+				}
+				if err != nil {
+					stop()
+					return err
+				}
+			}
+		}
+		if err = stop(); err != nil {
+			return err
+		}
+
+		if !empty {
+			if _, err = io.WriteString(w, "]"); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, "}")
+	return err
+}
+
+// streamLevelLogs writes the logs of l with any of the levels
+// as NDJSON, holding only a chunk of logs in memory at any time
+func streamLevelLogs(l Logger, w io.Writer, levels ...LogLevel) error {
+	ch, stop := l.GetLogsBuffered(l.FirstIndex(), l.NLogs())
+	for logs := range ch {
+		for _, log := range logs {
+			if len(levels) > 0 && !log.LevelMatchAny(levels...) {
+				continue
+			}
+
+			b, err := exportedJSON(log)
+			if err == nil {
+				_, err = w.Write(append(b, '\n'))
+			}
+			if err != nil {
+				stop()
+				return err
+			}
+		}
+	}
+	return stop()
+}
+
+// filterLogs returns the logs of l for which match returns true,
+// reading only a chunk of logs at a time from the storage
+func filterLogs(l Logger, match func(Log) bool) ([]Log, error) {
+	res := make([]Log, 0)
+	err := l.ForEachLog(l.FirstIndex(), l.NLogs(), func(_ int, log Log) bool {
+		if match(log) {
+			res = append(res, log)
+		}
+		return true
+	})
+	return res, err
+}
+
+// filterLogsBuffered streams the logs of l for which match returns true,
+// in batches holding the matching logs of a chunk; the chunks without
+// a matching log produce no batch
+func filterLogsBuffered(l Logger, match func(Log) bool) (<-chan []Log, func() error) {
+	in, stopIn := l.GetLogsBuffered(l.FirstIndex(), l.NLogs())
+	ch := make(chan []Log)
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+		defer close(ch)
+
+		for logs := range in {
+			matched := make([]Log, 0)
+			for _, log := range logs {
+				if match(log) {
+					matched = append(matched, log)
+				}
+			}
+			if len(matched) == 0 {
+				continue
+			}
+
+			select {
+			case ch <- matched:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	stop := func() error {
+		once.Do(func() { close(done) })
+		err := stopIn()
+		<-finished
+		return err
+	}
+
+	return ch, stop
+}
+
+// searchLogs returns the logs of l matching the pattern,
+// holding only a chunk of logs in memory at any time
+func searchLogs(l Logger, pattern string) ([]Log, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Log, 0)
+	ch, stop := l.GetLogsBuffered(l.FirstIndex(), l.NLogs())
+	for logs := range ch {
+		res = append(res, logsSearch(logs, re)...)
+	}
+	return res, stop()
+}
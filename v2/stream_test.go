@@ -0,0 +1,227 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportGroupedJSONNumericLevels(t *testing.T) {
+	SetJSONNumericLevel(true)
+	t.Cleanup(func() { SetJSONNumericLevel(false) })
+
+	l := NewLogger(nil)
+	l.AddLog(LOG_LEVEL_INFO, "info", "", false)
+	l.AddLog(LOG_LEVEL_ERROR, "error", "", false)
+
+	var buf bytes.Buffer
+	if err := l.ExportGroupedJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var groups map[string][]Log
+	if err := json.Unmarshal(buf.Bytes(), &groups); err != nil {
+		t.Fatalf("invalid JSON %s: %v", buf.String(), err)
+	}
+	if len(groups["info"]) != 1 || len(groups["error"]) != 1 {
+		t.Errorf("groups = %s, want one info and one error log", buf.String())
+	}
+}
+
+func TestFilterByTagsAcrossChunks(t *testing.T) {
+	setChunkSize(t, 10)
+	l, _ := newTestHugeLogger(t)
+
+	rare := map[int]bool{ 3: true, 47: true, 95: true }
+	for i := 0; i < 100; i++ {
+		log := Log{ l: newLog(LOG_LEVEL_INFO, fmt.Sprintf("log %d", i), "") }
+		if rare[i] {
+			log.addTags("rare")
+		}
+		l.AddLogs([]Log{ log }, false)
+	}
+
+	logs, err := l.FilterByTags("rare")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(messages(logs), "|"); got != "log 3|log 47|log 95" {
+		t.Errorf("FilterByTags = %q", got)
+	}
+
+	ch, stop := l.FilterLogsBuffered(func(log Log) bool {
+		return log.Match("rare")
+	})
+	batches := 0
+	for batch := range ch {
+		batches ++
+		if len(batch) != 1 {
+			t.Errorf("batch %d has %d logs, want 1", batches, len(batch))
+		}
+	}
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+	if batches != 3 {
+		t.Errorf("got %d batches, want 3", batches)
+	}
+}
+
+func TestImportJSONLStorageError(t *testing.T) {
+	src := NewLogger(nil)
+	for i := 0; i < 5; i++ {
+		src.AddLog(LOG_LEVEL_INFO, fmt.Sprintf("log %d", i), "", false)
+	}
+	var buf bytes.Buffer
+	if _, err := src.ExportJSONL(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	l, _ := newTestHugeLogger(t)
+	// writing to a closed chunk file makes the storage fail
+	l.(*logger).logs.(*fileLogStorage).f.Close()
+
+	if err := l.ImportJSONL(&buf); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("ImportJSONL error = %v, want the failure of line 1", err)
+	}
+}
+
+func TestImportJSONLSkipsDisabledLevels(t *testing.T) {
+	src := NewLogger(nil)
+	src.AddLog(LOG_LEVEL_DEBUG, "debug", "", false)
+	src.AddLog(LOG_LEVEL_ERROR, "error", "", false)
+	var buf bytes.Buffer
+	if _, err := src.ExportJSONL(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	l := NewLogger(nil)
+	l.SetMinLevel(LOG_LEVEL_WARNING)
+	if err := l.ImportJSONL(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if n := l.NLogs(); n != 1 {
+		t.Errorf("NLogs = %d, want 1", n)
+	}
+}
+
+func TestStreamLogsErrorWithoutStop(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	ch, _ := streamLogs(100, func(batch int) ([]Log, error) {
+		if batch == 1 {
+			return nil, errors.New("broken chunk")
+		}
+		return []Log{}, nil
+	})
+	for range ch {
+	}
+
+	// stop is never called: the goroutines must end on their own
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("%d goroutines left running after the stream failed", n - before)
+	}
+}
+
+func BenchmarkGetLogsBuffered(b *testing.B) {
+	old := LogChunkSize
+	LogChunkSize = 1000
+	b.Cleanup(func() { LogChunkSize = old })
+
+	l, err := NewHugeLogger(nil, b.TempDir(), "bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer l.Close()
+
+	logs := make([]Log, 50000)
+	for i := range logs {
+		logs[i] = createLog(LOG_LEVEL_INFO, fmt.Sprintf("log %d", i), "", false)
+	}
+	l.AddLogs(logs, false)
+
+	for _, workers := range []int{ 1, 4 } {
+		b.Run(fmt.Sprintf("concurrency-%d", workers), func(b *testing.B) {
+			oldWorkers := LogReadConcurrency
+			LogReadConcurrency = workers
+			defer func() { LogReadConcurrency = oldWorkers }()
+
+			for i := 0; i < b.N; i++ {
+				ch, stop := l.GetLogsBuffered(0, l.NLogs())
+				for range ch {
+				}
+				if err := stop(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestGetLogsByTimeRange(t *testing.T) {
+	setChunkSize(t, 5)
+	huge, _ := newTestHugeLogger(t)
+	base := time.Date(2024, 6, 7, 14, 0, 0, 0, time.UTC)
+
+	logs := make([]Log, 30)
+	for i := range logs {
+		logs[i] = createLog(LOG_LEVEL_INFO, fmt.Sprintf("log %d", i), "", false)
+		logs[i].l.date = base.Add(time.Duration(i) * time.Minute)
+	}
+
+	for name, l := range map[string]Logger{ "memory": NewLogger(nil), "huge": huge } {
+		l.AddLogs(logs, false)
+
+		got := strings.Join(messages(l.GetLogsByTimeRange(base.Add(7 * time.Minute), base.Add(13 * time.Minute))), ",")
+		if want := "log 7,log 8,log 9,log 10,log 11,log 12"; got != want {
+			t.Errorf("%s: logs %s, want %s", name, got, want)
+		}
+
+		for _, r := range [][2]time.Time{
+			{ base.Add(-time.Hour), base },
+			{ base.Add(time.Hour), base.Add(2 * time.Hour) },
+			{ base.Add(5 * time.Minute), base.Add(5 * time.Minute) },
+			{ base.Add(90 * time.Second), base.Add(100 * time.Second) },
+		} {
+			if found := l.GetLogsByTimeRange(r[0], r[1]); len(found) != 0 {
+				t.Errorf("%s: range %v - %v returned %v, want no logs", name, r[0], r[1], messages(found))
+			}
+		}
+	}
+}
+
+func TestCloneGetLogsBufferedOutOfRange(t *testing.T) {
+	l := NewLogger(nil)
+	c := l.Clone(nil)
+	for i := 0; i < 3; i++ {
+		c.Print(LOG_LEVEL_INFO, i)
+	}
+
+	for _, r := range [][2]int{ { 0, 10 }, { -1, 2 }, { 2, 4 } } {
+		ch, stop := c.GetLogsBuffered(r[0], r[1])
+		for logs := range ch {
+			t.Errorf("range %v: received %d logs", r, len(logs))
+		}
+		if err := stop(); !errors.Is(err, ErrIndexOutOfRange) {
+			t.Errorf("range %v: error %v, want ErrIndexOutOfRange", r, err)
+		}
+	}
+
+	ch, stop := c.GetLogsBuffered(1, 3)
+	var got []string
+	for logs := range ch {
+		got = append(got, messages(logs)...)
+	}
+	if err := stop(); err != nil || strings.Join(got, " ") != "1 2" {
+		t.Errorf("range [1, 3): logs %q and error %v", got, err)
+	}
+}
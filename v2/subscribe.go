@@ -0,0 +1,233 @@
+package logger
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// SubscriptionBufferSize is the size of the channel buffer of every
+// subscription. What happens when a subscriber is too slow and its
+// buffer is full depends on the subscription OverflowPolicy
+var SubscriptionBufferSize = 64
+
+// OverflowPolicy decides what happens to a new log when the
+// channel buffer of a subscription is full
+type OverflowPolicy int
+
+const (
+	// OVERFLOW_DROP_NEWEST drops the new log; this is the default
+	// and it never blocks logging
+	OVERFLOW_DROP_NEWEST OverflowPolicy = iota
+	// OVERFLOW_DROP_OLDEST discards the oldest log in the buffer
+	// to make room for the new one, without blocking
+	OVERFLOW_DROP_OLDEST
+	// OVERFLOW_BLOCK waits for the subscriber to receive the log: a
+	// stuck subscriber blocks every new log of the Logger until
+	// the subscription is canceled
+	OVERFLOW_BLOCK
+	// OVERFLOW_GROW keeps every log in an unbounded queue, so that
+	// nothing is dropped and logging is never blocked, at the cost of memory
+	OVERFLOW_GROW
+)
+
+type subscription struct {
+	ch      chan Log
+	match   func(Log) bool
+	policy  OverflowPolicy
+	done    chan struct{}
+	dropped atomic.Uint64
+
+	// used only with OVERFLOW_GROW
+	m      sync.Mutex
+	queue  []Log
+	notify chan struct{}
+}
+
+// Subscription is a subscription to the logs of a Logger created
+// with SubscribeWith. C receives the logs and is closed after Cancel
+type Subscription struct {
+	C      <-chan Log
+	sub    *subscription
+	cancel func()
+}
+
+// Dropped returns the number of logs that were not delivered
+// to the subscriber due to the OverflowPolicy
+func (s *Subscription) Dropped() uint64 {
+	return s.sub.dropped.Load()
+}
+
+// Cancel ends the subscription and closes C
+func (s *Subscription) Cancel() {
+	s.cancel()
+}
+
+// broadcaster dispatches every new log of a Logger to its subscriptions
+type broadcaster struct {
+	m    sync.RWMutex
+	subs map[*subscription]struct{}
+}
+
+func (b *broadcaster) subscribe(policy OverflowPolicy, match func(Log) bool) *Subscription {
+	sub := &subscription{
+		ch:     make(chan Log, SubscriptionBufferSize),
+		match:  match,
+		policy: policy,
+		done:   make(chan struct{}),
+	}
+	if policy == OVERFLOW_GROW {
+		sub.notify = make(chan struct{}, 1)
+		go sub.pump()
+	}
+
+	b.m.Lock()
+	if b.subs == nil {
+		b.subs = make(map[*subscription]struct{})
+	}
+	b.subs[sub] = struct{}{}
+	b.m.Unlock()
+
+	var once sync.Once
+	return &Subscription{
+		C:   sub.ch,
+		sub: sub,
+		cancel: func() {
+			once.Do(func() {
+				// closing done first releases any dispatch blocked on this
+				// subscription, which would otherwise hold the read lock
+				close(sub.done)
+
+				b.m.Lock()
+				delete(b.subs, sub)
+				b.m.Unlock()
+
+				if sub.policy != OVERFLOW_GROW {
+					close(sub.ch)
+				}
+			})
+		},
+	}
+}
+
+// deliver sends the log to the subscriber following its OverflowPolicy
+func (sub *subscription) deliver(log Log) {
+	switch sub.policy {
+	case OVERFLOW_DROP_OLDEST:
+		for {
+			select {
+			case sub.ch <- log:
+				return
+			default:
+			}
+
+			select {
+			case <-sub.ch:
+				sub.dropped.Add(1)
+			default:
+			}
+		}
+	case OVERFLOW_BLOCK:
+		select {
+		case sub.ch <- log:
+		case <-sub.done:
+			sub.dropped.Add(1)
+		}
+	case OVERFLOW_GROW:
+		sub.m.Lock()
+		sub.queue = append(sub.queue, log)
+		sub.m.Unlock()
+
+		select {
+		case sub.notify <- struct{}{}:
+		default:
+		}
+	default:
+		select {
+		case sub.ch <- log:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// pump moves the queued logs of an OVERFLOW_GROW subscription
+// to its channel, which is closed when the subscription is canceled
+func (sub *subscription) pump() {
+	defer close(sub.ch)
+
+	for {
+		select {
+		case <-sub.notify:
+		case <-sub.done:
+			return
+		}
+
+		sub.m.Lock()
+		queue := sub.queue
+		sub.queue = nil
+		sub.m.Unlock()
+
+		for _, log := range queue {
+			select {
+			case sub.ch <- log:
+			case <-sub.done:
+				return
+			}
+		}
+	}
+}
+
+func (b *broadcaster) dispatch(log Log) {
+	b.m.RLock()
+	defer b.m.RUnlock()
+
+	for sub := range b.subs {
+		if sub.match != nil && !sub.match(log) {
+			continue
+		}
+
+		sub.deliver(log)
+	}
+}
+
+// Subscribe returns a channel that receives every new log created by
+// the Logger (or by any of its clones) and a function to cancel the
+// subscription, which closes the channel. When the subscriber is too slow
+// the new logs are dropped (see OVERFLOW_DROP_NEWEST)
+func (b *broadcaster) Subscribe() (<-chan Log, func()) {
+	s := b.subscribe(OVERFLOW_DROP_NEWEST, nil)
+	return s.C, s.cancel
+}
+
+// SubscribeMatching is like Subscribe, but the channel only
+// receives the logs that have all the given tags (see Log.Match)
+func (b *broadcaster) SubscribeMatching(tags ...string) (<-chan Log, func()) {
+	s := b.subscribe(OVERFLOW_DROP_NEWEST, func(l Log) bool {
+		return l.Match(tags...)
+	})
+	return s.C, s.cancel
+}
+
+// SubscribeMatchingAny is like Subscribe, but the channel only
+// receives the logs that have at least one of the given tags
+// (see Log.MatchAny)
+func (b *broadcaster) SubscribeMatchingAny(tags ...string) (<-chan Log, func()) {
+	s := b.subscribe(OVERFLOW_DROP_NEWEST, func(l Log) bool {
+		return l.MatchAny(tags...)
+	})
+	return s.C, s.cancel
+}
+
+// SubscribeWith is like SubscribeMatching, but the behaviour with a slow
+// subscriber is decided by the given OverflowPolicy and the returned
+// Subscription reports how many logs were dropped. With no tags,
+// every log is received
+func (b *broadcaster) SubscribeWith(policy OverflowPolicy, tags ...string) *Subscription {
+	var match func(Log) bool
+	if len(tags) > 0 {
+		match = func(l Log) bool {
+			return l.Match(tags...)
+		}
+	}
+	return b.subscribe(policy, match)
+}
@@ -0,0 +1,87 @@
+package logger
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// logWithin logs n messages and fails the test if it takes
+// longer than a second, meaning that logging was blocked
+func logWithin(t *testing.T, l Logger, n int) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < n; i++ {
+			l.Print(LOG_LEVEL_INFO, fmt.Sprintf("log %d", i))
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("logging blocked by a stalled subscriber")
+	}
+}
+
+func TestStalledSubscriber(t *testing.T) {
+	old := SubscriptionBufferSize
+	SubscriptionBufferSize = 4
+	t.Cleanup(func() { SubscriptionBufferSize = old })
+
+	for _, c := range []struct {
+		policy  OverflowPolicy
+		dropped uint64
+		first   string
+	}{
+		{ OVERFLOW_DROP_NEWEST, 6, "log 0" },
+		{ OVERFLOW_DROP_OLDEST, 6, "log 6" },
+		{ OVERFLOW_GROW, 0, "log 0" },
+	} {
+		l := NewLogger(nil)
+		sub := l.SubscribeWith(c.policy)
+
+		// nobody receives from the subscription while logging
+		logWithin(t, l, 10)
+
+		if d := sub.Dropped(); d != c.dropped {
+			t.Errorf("policy %d: Dropped = %d, want %d", c.policy, d, c.dropped)
+		}
+		if log := <-sub.C; log.Message() != c.first {
+			t.Errorf("policy %d: first log received %q, want %q", c.policy, log.Message(), c.first)
+		}
+		sub.Cancel()
+	}
+}
+
+func TestStalledBlockingSubscriber(t *testing.T) {
+	old := SubscriptionBufferSize
+	SubscriptionBufferSize = 1
+	t.Cleanup(func() { SubscriptionBufferSize = old })
+
+	l := NewLogger(nil)
+	sub := l.SubscribeWith(OVERFLOW_BLOCK)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.Print(LOG_LEVEL_INFO, "buffered")
+		l.Print(LOG_LEVEL_INFO, "blocked")
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("logging not blocked by the stalled subscriber")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	sub.Cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("logging still blocked after Cancel")
+	}
+	if d := sub.Dropped(); d != 1 {
+		t.Errorf("Dropped = %d, want 1", d)
+	}
+}
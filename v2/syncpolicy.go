@@ -0,0 +1,44 @@
+package logger
+
+import "time"
+
+// SyncPolicy decides when a HugeLogger syncs its chunk files to
+// the disk (see SetSyncPolicy): it's one of SYNC_NEVER and
+// SYNC_EVERY_LOG or an interval created with SyncInterval
+type SyncPolicy time.Duration
+
+const (
+	// SYNC_NEVER leaves the writes to the operating system, which
+	// writes them to the disk on its own: the files are synced only
+	// when the Logger is flushed or closed. It's the default
+	SYNC_NEVER SyncPolicy = 0
+	// SYNC_EVERY_LOG syncs the chunk file after every log (or every
+	// batch of logs added together), so that a log is never lost once
+	// stored, even in a system crash, at the cost of a much lower
+	// throughput, bound by the latency of the disk
+	SYNC_EVERY_LOG SyncPolicy = -1
+)
+
+// SyncInterval returns the SyncPolicy that syncs the current chunk file
+// at most every d, if anything was written, so that at most the logs of
+// the last d can be lost in a system crash, without paying the cost of
+// a sync for every log. A d less than or equal to zero is SYNC_NEVER
+func SyncInterval(d time.Duration) SyncPolicy {
+	if d <= 0 {
+		return SYNC_NEVER
+	}
+	return SyncPolicy(d)
+}
+
+func (policy SyncPolicy) String() string {
+	switch {
+	case policy == SYNC_NEVER:
+		return "never"
+	case policy == SYNC_EVERY_LOG:
+		return "every log"
+	case policy > 0:
+		return "every " + time.Duration(policy).String()
+	default:
+		return "???"
+	}
+}
@@ -0,0 +1,94 @@
+package logger
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSyncEveryLogVisibleToFreshDescriptor(t *testing.T) {
+	l, _ := newTestHugeLogger(t)
+	l.SetSyncPolicy(SYNC_EVERY_LOG)
+	fls := l.(*logger).logs.(*fileLogStorage)
+
+	l.AddLog(LOG_LEVEL_INFO, "durable", "", false)
+
+	b, err := os.ReadFile(fls.f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte(`"message":"durable"`)) {
+		t.Errorf("chunk file read from a fresh descriptor = %q", b)
+	}
+	if fls.dirty {
+		t.Error("the chunk file is still dirty after the sync")
+	}
+}
+
+func TestSyncEveryLogReportsSyncError(t *testing.T) {
+	l, _ := newTestHugeLogger(t)
+	l.SetSyncPolicy(SYNC_EVERY_LOG)
+	fls := l.(*logger).logs.(*fileLogStorage)
+
+	// the writes to /dev/null succeed, while its sync fails
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if devNull.Sync() == nil {
+		devNull.Close()
+		t.Skip("syncing the null device does not fail on this system")
+	}
+
+	chunk := fls.f
+	fls.f = devNull
+	defer func() {
+		fls.f = chunk
+		devNull.Close()
+	}()
+
+	log := createLog(LOG_LEVEL_INFO, "lost", "", false)
+	if p, err := fls.addLog(log, false); err == nil || p != -1 {
+		t.Errorf("addLog = %d, %v, want the sync error", p, err)
+	}
+	if ps, err := fls.addLogs([]Log{ log, log }, false); err == nil || len(ps) != 0 {
+		t.Errorf("addLogs = %v, %v, want the sync error", ps, err)
+	}
+	if n := fls.nLogs(); n != 0 {
+		t.Errorf("nLogs = %d, want 0", n)
+	}
+}
+
+func TestSyncIntervalCleansTheChunkFile(t *testing.T) {
+	setChunkSize(t, 50)
+	l, _ := newTestHugeLogger(t)
+	l.SetSyncPolicy(SyncInterval(5 * time.Millisecond))
+	fls := l.(*logger).logs.(*fileLogStorage)
+
+	// the writes go on while the periodic sync runs, across many rollovers
+	for i := 0; i < 500; i++ {
+		l.AddLog(LOG_LEVEL_INFO, "message", "", false)
+		if i % 100 == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		fls.rwm.RLock()
+		dirty := fls.dirty
+		fls.rwm.RUnlock()
+		if !dirty {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the chunk file was never synced")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if n := l.NLogs(); n != 500 {
+		t.Errorf("NLogs = %d, want 500", n)
+	}
+}
@@ -0,0 +1,125 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SyslogTimeFormat is the RFC 5424 timestamp format, with microseconds
+const SyslogTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// SyslogFormatter writes the logs as RFC 5424 syslog messages, like
+//
+//	<14>1 2006-01-02T15:04:05.000000Z07:00 host app 1234 - - a message
+//
+// with the priority computed from the facility and the level of the log
+// (see SyslogSeverity). The extra, if any, follows the message on a new
+// line. The logs are never colored
+type SyslogFormatter struct {
+	Facility int    // Facility is the syslog facility, like 1 (user) or 16 to 23 (local0 to local7)
+	Hostname string // Hostname is the host name sent, os.Hostname() if empty
+	AppName  string // AppName is the app tag sent, the name of the program if empty
+}
+
+func (f SyslogFormatter) Format(l Log, colored bool) string {
+	hostname := f.Hostname
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+	appName := f.AppName
+	if appName == "" {
+		appName = filepath.Base(os.Args[0])
+	}
+
+	msg := l.Message()
+	if extra := l.Extra(); extra != "" {
+		msg += "\n" + extra
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d - - %s",
+		f.Facility * 8 + SyslogSeverity(l.Level()),
+		l.Date().Format(SyslogTimeFormat),
+		syslogHeaderField(hostname),
+		syslogHeaderField(appName),
+		os.Getpid(), msg,
+	)
+}
+
+// SyslogSeverity returns the syslog severity of the level: FATAL is
+// crit (2), ERROR is err (3), WARNING is warning (4), INFO is info (6)
+// and DEBUG is debug (7); any other level is notice (5)
+func SyslogSeverity(level LogLevel) int {
+	switch level {
+	case LOG_LEVEL_FATAL:
+		return 2
+	case LOG_LEVEL_ERROR:
+		return 3
+	case LOG_LEVEL_WARNING:
+		return 4
+	case LOG_LEVEL_INFO:
+		return 6
+	case LOG_LEVEL_DEBUG:
+		return 7
+	default:
+		return 5
+	}
+}
+
+// syslogHeaderField returns s without spaces, or the nil value "-" if empty
+func syslogHeaderField(s string) string {
+	s = strings.Join(strings.Fields(s), "_")
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+type syslogWriter struct {
+	*netWriter
+	formatter SyslogFormatter
+	framed    bool
+}
+
+// NewSyslogWriter returns a writer that sends the logs to a syslog daemon
+// over the given network, like "udp" or "tcp", formatted with a
+// SyslogFormatter with the given facility, so it can be used as the
+// output of a Logger. Every log is sent in its own message; over TCP
+// the messages are framed by their length (RFC 6587). Like a NetWriter,
+// if the connection is lost the messages are buffered and the connection
+// is reestablished (see NewNetWriter and its options). An error is
+// returned only if the first connection fails
+func NewSyslogWriter(network, addr string, facility int, opts ...NetWriterOption) (io.WriteCloser, error) {
+	w, err := newNetWriter(network, addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &syslogWriter{
+		netWriter: w,
+		formatter: SyslogFormatter{ Facility: facility },
+		framed:    strings.HasPrefix(network, "tcp"),
+	}, nil
+}
+
+// WriteLog sends the log as a syslog message
+func (w *syslogWriter) WriteLog(log Log) error {
+	msg := w.formatter.Format(log, false)
+	if w.framed {
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+
+	_, err := w.netWriter.Write([]byte(msg))
+	return err
+}
+
+// Write sends p as the message of a syslog message with the notice severity
+func (w *syslogWriter) Write(p []byte) (n int, err error) {
+	err = w.WriteLog(createLog(LOG_LEVEL_BLANK, strings.TrimSuffix(string(p), "\n"), "", false))
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
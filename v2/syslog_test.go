@@ -0,0 +1,50 @@
+package logger
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogWriterFramesOverTCP(t *testing.T) {
+	ln := listenTCP(t)
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var n int
+		br := bufio.NewReader(conn)
+		if _, err := fmt.Fscanf(br, "%d ", &n); err != nil {
+			return
+		}
+		msg := make([]byte, n)
+		if _, err := br.Read(msg); err == nil {
+			received <- string(msg)
+		}
+	}()
+
+	w, err := NewSyslogWriter("tcp", ln.Addr().String(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	l := NewLogger(w)
+	l.Print(LOG_LEVEL_WARNING, "disk low")
+
+	select {
+	case msg := <-received:
+		// user facility (1) and warning severity (4)
+		if !strings.HasPrefix(msg, "<12>1 ") || !strings.HasSuffix(msg, " - - disk low") {
+			t.Errorf("received %q", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no syslog message received")
+	}
+}
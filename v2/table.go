@@ -0,0 +1,133 @@
+package logger
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// RenderTable renders the logs as a table with the columns time,
+// level and message, aligned and fitting in width characters, for
+// example for a command line tool. The messages are written on a single
+// line and truncated with "…" when they do not fit; the terminal colors
+// they contain are kept and do not count toward the width. A width
+// of zero or less does not truncate anything
+func RenderTable(logs []Log, width int) string {
+	const sep = "  "
+
+	header := [3]string{ "TIME", "LEVEL", "MESSAGE" }
+	rows := make([][3]string, 0, len(logs))
+	widths := [3]int{ len(header[0]), len(header[1]), len(header[2]) }
+
+	for _, log := range logs {
+		row := [3]string{
+			formatDate(log.Date()),
+			strings.TrimSpace(log.Level().String()),
+			strings.Join(strings.Fields(log.RawMessage()), " "),
+		}
+		for i, cell := range row {
+			if w := visibleWidth(cell); w > widths[i] {
+				widths[i] = w
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	if width > 0 {
+		msgWidth := width - widths[0] - widths[1] - 2*len(sep)
+		if msgWidth < 1 {
+			msgWidth = 1
+		}
+		if widths[2] > msgWidth {
+			widths[2] = msgWidth
+		}
+	}
+
+	var sb strings.Builder
+	writeRow := func(row [3]string) {
+		for i, cell := range row {
+			if i == 2 {
+				sb.WriteString(truncateVisible(cell, widths[2]))
+				break
+			}
+			sb.WriteString(cell)
+			sb.WriteString(strings.Repeat(" ", widths[i] - visibleWidth(cell)))
+			sb.WriteString(sep)
+		}
+		sb.WriteByte('\n')
+	}
+
+	writeRow(header)
+	for _, row := range rows {
+		writeRow(row)
+	}
+
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// visibleWidth returns the number of characters of s
+// displayed on a terminal, ignoring the color sequences
+func visibleWidth(s string) int {
+	w := 0
+	for i := 0; i < len(s); {
+		if n := escapeLen(s[i:]); n > 0 {
+			i += n
+			continue
+		}
+
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		w++
+	}
+	return w
+}
+
+// truncateVisible truncates s to at most n visible characters, ending with
+// "…" if anything was cut. The color sequences are kept and, if any is found,
+// the colors are reset at the end
+func truncateVisible(s string, n int) string {
+	if visibleWidth(s) <= n {
+		return s
+	}
+
+	var sb strings.Builder
+	colored := false
+	w := 0
+	for i := 0; i < len(s); {
+		if l := escapeLen(s[i:]); l > 0 {
+			sb.WriteString(s[i:i+l])
+			colored = true
+			i += l
+			continue
+		}
+
+		if w == n-1 {
+			break
+		}
+
+		_, size := utf8.DecodeRuneInString(s[i:])
+		sb.WriteString(s[i:i+size])
+		i += size
+		w++
+	}
+
+	sb.WriteString("…")
+	if colored {
+		sb.WriteString(DEFAULT_COLOR)
+	}
+	return sb.String()
+}
+
+// escapeLen returns the length of the terminal control
+// sequence (like a color) at the start of s, or 0
+func escapeLen(s string) int {
+	if len(s) < 2 || s[0] != '\x1b' || s[1] != '[' {
+		return 0
+	}
+
+	for i := 2; i < len(s); i++ {
+		if s[i] >= 0x40 && s[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return 0
+}
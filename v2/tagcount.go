@@ -0,0 +1,103 @@
+package logger
+
+import (
+	"strings"
+	"sync"
+)
+
+// tagCounter keeps how many logs were created for every level and,
+// for every level, how many of them carry each tag, so that the counts
+// are available without scanning the logs
+type tagCounter struct {
+	m      sync.Mutex
+	counts map[LogLevel]map[string]int
+	levels map[LogLevel]int
+}
+
+func (c *tagCounter) count(log Log) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.counts == nil {
+		c.counts = make(map[LogLevel]map[string]int)
+		c.levels = make(map[LogLevel]int)
+	}
+	c.levels[log.Level()]++
+
+	tags := c.counts[log.Level()]
+	if tags == nil {
+		tags = make(map[string]int)
+		c.counts[log.Level()] = tags
+	}
+	for _, tag := range log.Tags() {
+		tags[tag]++
+	}
+}
+
+// TagCounts returns how many logs of the given levels (or of every
+// level, if none is given) carry each tag, for example to build faceted
+// filters. The counts are kept up to date as the logs are created, so no
+// log is read; they include every log created by the Logger (with the tags
+// it has at this point, so a clone doesn't see the tags added by its
+// parent), even if it was later removed from the storage
+func (c *tagCounter) TagCounts(levels ...LogLevel) map[string]int {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	res := make(map[string]int)
+	add := func(tags map[string]int) {
+		for tag, n := range tags {
+			res[tag] += n
+		}
+	}
+
+	if len(levels) == 0 {
+		for _, tags := range c.counts {
+			add(tags)
+		}
+		return res
+	}
+
+	seen := make(map[LogLevel]bool, len(levels))
+	for _, level := range levels {
+		if !seen[level] {
+			seen[level] = true
+			add(c.counts[level])
+		}
+	}
+	return res
+}
+
+// LevelCounts returns how many logs of each level were created, for
+// example to show "142 errors, 3 fatals" on a dashboard. Like TagCounts,
+// the counts are kept up to date as the logs are created, so they are
+// read without scanning the logs, also for a HugeLogger; they include
+// every log created by the Logger or by its clones, even if it was later
+// removed from the storage, while a clone counts only its own logs
+func (c *tagCounter) LevelCounts() map[LogLevel]int {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	res := make(map[LogLevel]int, len(c.levels))
+	for level, n := range c.levels {
+		res[level] = n
+	}
+	return res
+}
+
+// levelCounts returns how many logs were created for each level,
+// keyed by the lowercase level name ("blank" for LOG_LEVEL_BLANK)
+func (c *tagCounter) levelCounts() map[string]int {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	res := make(map[string]int, len(c.levels))
+	for level, n := range c.levels {
+		name := strings.TrimSpace(strings.ToLower(level.String()))
+		if name == "" {
+			name = "blank"
+		}
+		res[name] += n
+	}
+	return res
+}
@@ -0,0 +1,40 @@
+package logger
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLevelCounts(t *testing.T) {
+	setChunkSize(t, 5)
+	huge, _ := newTestHugeLogger(t)
+
+	for name, l := range map[string]Logger{ "memory": NewLogger(nil), "huge": huge } {
+		mix := map[LogLevel]int{
+			LOG_LEVEL_INFO:    7,
+			LOG_LEVEL_DEBUG:   3,
+			LOG_LEVEL_WARNING: 2,
+			LOG_LEVEL_ERROR:   142,
+			LOG_LEVEL_FATAL:   3,
+		}
+		for level, n := range mix {
+			for i := 0; i < n; i++ {
+				l.AddLog(level, "message", "", false)
+			}
+		}
+
+		c := l.Clone(nil, "clone")
+		c.AddLog(LOG_LEVEL_ERROR, "from the clone", "", false)
+		c.AddLog(LOG_LEVEL_WARNING, "from the clone", "", false)
+		mix[LOG_LEVEL_ERROR] ++
+		mix[LOG_LEVEL_WARNING] ++
+
+		if got := l.LevelCounts(); !reflect.DeepEqual(got, mix) {
+			t.Errorf("%s: LevelCounts = %v, want %v", name, got, mix)
+		}
+		want := map[LogLevel]int{ LOG_LEVEL_ERROR: 1, LOG_LEVEL_WARNING: 1 }
+		if got := c.LevelCounts(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: clone LevelCounts = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package logger
+
+import "io"
+
+// taggedWriter is the io.Writer returned by TaggedWriter
+type taggedWriter struct {
+	l     Logger
+	level LogLevel
+	tags  []string
+	partialLine
+}
+
+// TaggedWriter returns an io.Writer that creates a log in l for each
+// line written, like Logger.Write but with the given level, carrying the
+// given tags in addition to the ones of l, for example to tag everything
+// written by the output of a subprocess. A line split across many writes
+// is logged once completed. The caller of the log is recorded if enabled
+// in l (see Logger.EnableCaller). Nothing is logged if the level is not
+// enabled (see Logger.Enabled)
+func TaggedWriter(l Logger, level LogLevel, tags ...string) io.Writer {
+	return &taggedWriter{ l: l, level: level, tags: tags }
+}
+
+func (w *taggedWriter) Write(p []byte) (n int, err error) {
+	lines := w.lines(p)
+	if !w.l.Enabled(w.level) {
+		return len(p), nil
+	}
+
+	for _, line := range lines {
+		log := createLog(w.level, line, "", w.l.callerEnabled())
+		log.addTags(w.tags...)
+		w.l.newLog(log, true)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,47 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTaggedWriter(t *testing.T) {
+	l := NewLogger(nil)
+	l.EnableCaller()
+	w := TaggedWriter(l, LOG_LEVEL_INFO, "subproc")
+
+	w.Write([]byte("starting\nlistening on "))
+	w.Write([]byte(":8080\n"))
+	l.Print(LOG_LEVEL_INFO, "untagged")
+
+	logs, err := l.FilterByTags("subproc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(messages(logs), "|")
+	if got != "starting|listening on :8080" {
+		t.Errorf("logs tagged subproc = %q", got)
+	}
+
+	for _, log := range logs {
+		if log.Level() != LOG_LEVEL_INFO {
+			t.Errorf("log %q has level %v, want info", log.Message(), log.Level())
+		}
+		// the tests belong to the package, so the caller
+		// is the first frame outside of it
+		if log.Caller() == "" {
+			t.Errorf("log %q has no caller", log.Message())
+		}
+	}
+}
+
+func TestTaggedWriterDisabledLevel(t *testing.T) {
+	l := NewLogger(nil)
+	l.SetMinLevel(LOG_LEVEL_INFO)
+	w := TaggedWriter(l, LOG_LEVEL_DEBUG, "subproc")
+
+	w.Write([]byte("noise\n"))
+	if n := l.NLogs(); n != 0 {
+		t.Errorf("got %d logs, want none", n)
+	}
+}
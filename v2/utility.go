@@ -2,9 +2,16 @@ package logger
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
 	"strings"
+	"time"
 )
 
 const (
@@ -18,12 +25,12 @@ const (
 	DARK_CYAN_COLOR = "\x1b[36m"
 	DARK_WHITE_COLOR = "\x1b[37m"
 	BRIGHT_BLACK_COLOR = "\x1b[90m"
-	BRIGHT_RED_COLOR = "\x1b[31m"
-	BRIGHT_GREEN_COLOR = "\x1b[32m"
-	BRIGHT_YELLOW_COLOR = "\x1b[33m"
-	BRIGHT_BLUE_COLOR = "\x1b[34m"
-	BRIGHT_MAGENTA_COLOR = "\x1b[35m"
-	BRIGHT_CYAN_COLOR = "\x1b[36m"
+	BRIGHT_RED_COLOR = "\x1b[91m"
+	BRIGHT_GREEN_COLOR = "\x1b[92m"
+	BRIGHT_YELLOW_COLOR = "\x1b[93m"
+	BRIGHT_BLUE_COLOR = "\x1b[94m"
+	BRIGHT_MAGENTA_COLOR = "\x1b[95m"
+	BRIGHT_CYAN_COLOR = "\x1b[96m"
 	WHITE_COLOR = "\x1b[37m"
 )
 
@@ -39,7 +46,26 @@ func RemoveTerminalColors(s string) string {
 	return s
 }
 
+// terminalDetector is the function used by ToTerminal
+var terminalDetector = isCharDevice
+
+// SetTerminalDetector overrides how ToTerminal decides if a writer
+// is a terminal (and so if the logs written to it are colored),
+// for example to test the colored output with a bytes.Buffer.
+// Passing nil restores the default detection
+func SetTerminalDetector(detector func(io.Writer) bool) {
+	if detector == nil {
+		detector = isCharDevice
+	}
+	terminalDetector = detector
+}
+
+// ToTerminal reports whether out is a terminal
 func ToTerminal(out io.Writer) bool {
+	return terminalDetector(out)
+}
+
+func isCharDevice(out io.Writer) bool {
 	switch out := out.(type) {
 	case *os.File:
 		stat, _ := out.Stat()
@@ -66,7 +92,7 @@ func IndentString(s string, n int) string {
 }
 
 func LogsToJSON(logs []Log) []byte {
-	b, err := json.Marshal(logs)
+	b, err := json.Marshal(exportedLogs(logs))
 	if err != nil {
 		panic(err)
 	}
@@ -80,7 +106,7 @@ func LogsToJSONIndented(logs []Log, spaces int) []byte {
 		indent += " "
 	}
 
-	b, err := json.MarshalIndent(logs, "", indent)
+	b, err := json.MarshalIndent(exportedLogs(logs), "", indent)
 	if err != nil {
 		panic(err)
 	}
@@ -88,14 +114,26 @@ func LogsToJSONIndented(logs []Log, spaces int) []byte {
 	return b
 }
 
+// exportedLogs encodes each log with the keys set with SetJSONKeys
+func exportedLogs(logs []Log) []json.RawMessage {
+	res := make([]json.RawMessage, 0, len(logs))
+	for _, l := range logs {
+		b, err := exportedJSON(l)
+		if err != nil {
+			panic(err)
+		}
+		res = append(res, b)
+	}
+
+	return res
+}
+
 func Fatal(a ...any) {
-	DefaultLogger.Print(LOG_LEVEL_FATAL, a...)
-	os.Exit(1)
+	DefaultLogger.Fatal(a...)
 }
 
 func Fatalf(format string, a ...any) {
-	DefaultLogger.Printf(LOG_LEVEL_FATAL, format, a...)
-	os.Exit(1)
+	DefaultLogger.Fatalf(format, a...)
 }
 
 func LogsMatch(logs []Log, tags ...string) []Log {
@@ -126,4 +164,90 @@ func LogsLevelMatch(logs []Log, levels ...LogLevel) []Log {
 		}
 	}
 	return lMatch
+}
+
+// LogsSearch returns the logs whose message or extra (without the terminal
+// colors) match the regular expression pattern. Prefix the pattern with
+// "(?i)" for a case-insensitive search. An invalid pattern is reported
+// with the error of regexp.Compile
+func LogsSearch(logs []Log, pattern string) ([]Log, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return logsSearch(logs, re), nil
+}
+
+func logsSearch(logs []Log, re *regexp.Regexp) []Log {
+	lMatch := make([]Log, 0)
+	for _, log := range logs {
+		if re.MatchString(log.Message()) || re.MatchString(log.Extra()) {
+			lMatch = append(lMatch, log)
+		}
+	}
+	return lMatch
+}
+
+// truncateDate rounds t down to a multiple of d, like time.Time.Truncate,
+// but based on the wall clock of the location of t instead of UTC
+func truncateDate(t time.Time, d time.Duration) time.Time {
+	_, offset := t.Zone()
+	shift := time.Duration(offset) * time.Second
+	return t.Add(shift).Truncate(d).Add(-shift)
+}
+
+// GroupLogsByInterval groups the logs into buckets of duration d (for
+// example to draw a histogram) keyed by the start of the bucket. Buckets
+// are aligned to the wall clock of the location of the log dates, so with
+// d equal to time.Hour every bucket starts at the beginning of an hour
+func GroupLogsByInterval(logs []Log, d time.Duration) map[time.Time][]Log {
+	groups := make(map[time.Time][]Log)
+	for _, log := range logs {
+		bucket := truncateDate(log.Date(), d)
+		groups[bucket] = append(groups[bucket], log)
+	}
+	return groups
+}
+
+// sortedBySeverity returns a copy of logs sorted from the most severe
+// to the least severe (see LogLevel.Severity) and then by date
+func sortedBySeverity(logs []Log) []Log {
+	sorted := make([]Log, len(logs))
+	copy(sorted, logs)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		si, sj := sorted[i].Level().Severity(), sorted[j].Level().Severity()
+		if si != sj {
+			return si > sj
+		}
+		return sorted[i].Date().Before(sorted[j].Date())
+	})
+	return sorted
+}
+
+// pkgPrefix is the prefix of every function name declared in this package,
+// used to skip the logger internals when looking for the caller of a log
+var pkgPrefix = strings.TrimSuffix(
+	runtime.FuncForPC(reflect.ValueOf(newLog).Pointer()).Name(),
+	"newLog",
+)
+
+// callerLocation returns the location of the first function in the
+// call stack that does not belong to this package
+func callerLocation() string {
+	pc := make([]uintptr, 16)
+	n := runtime.Callers(2, pc)
+	frames := runtime.CallersFrames(pc[:n])
+
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, pkgPrefix) {
+			dir, file := filepath.Split(frame.File)
+			return fmt.Sprintf("%s/%s:%d", filepath.Base(dir), file, frame.Line)
+		}
+
+		if !more {
+			return ""
+		}
+	}
 }
\ No newline at end of file
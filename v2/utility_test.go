@@ -0,0 +1,45 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestErrorFatalColors(t *testing.T) {
+	errorLog := Log{ l: newLog(LOG_LEVEL_ERROR, "message", "") }
+	fatalLog := Log{ l: newLog(LOG_LEVEL_FATAL, "message", "") }
+
+	if !strings.Contains(errorLog.Colored(), "\x1b[31m") {
+		t.Errorf("ERROR colored output %q, want the dark red code", errorLog.Colored())
+	}
+	if !strings.Contains(fatalLog.Colored(), "\x1b[91m") {
+		t.Errorf("FATAL colored output %q, want the bright red code", fatalLog.Colored())
+	}
+	if levelColor(LOG_LEVEL_ERROR) == levelColor(LOG_LEVEL_FATAL) {
+		t.Errorf("ERROR and FATAL share the color %q", levelColor(LOG_LEVEL_ERROR))
+	}
+
+	for _, color := range []string{ BRIGHT_RED_COLOR, BRIGHT_GREEN_COLOR, BRIGHT_YELLOW_COLOR, BRIGHT_BLUE_COLOR, BRIGHT_MAGENTA_COLOR, BRIGHT_CYAN_COLOR } {
+		if got := RemoveTerminalColors(color + "text" + DEFAULT_COLOR); got != "text" {
+			t.Errorf("RemoveTerminalColors left %q", got)
+		}
+	}
+}
+
+func TestSetLevelColor(t *testing.T) {
+	SetLevelColor(LOG_LEVEL_WARNING, BRIGHT_BLUE_COLOR)
+	t.Cleanup(func() { SetLevelColor(LOG_LEVEL_WARNING, "") })
+
+	log := Log{ l: newLog(LOG_LEVEL_WARNING, "message", "extra") }
+	if !strings.Contains(log.Colored(), BRIGHT_BLUE_COLOR) {
+		t.Errorf("Colored = %q, want the custom color", log.Colored())
+	}
+	if !strings.Contains(log.FullColored(), BRIGHT_BLUE_COLOR) {
+		t.Errorf("FullColored = %q, want the custom color", log.FullColored())
+	}
+
+	SetLevelColor(LOG_LEVEL_WARNING, "")
+	if strings.Contains(log.Colored(), BRIGHT_BLUE_COLOR) {
+		t.Errorf("Colored = %q after restoring the default color", log.Colored())
+	}
+}
@@ -0,0 +1,159 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ValidationReport describes the health of the log files
+// found in a directory (see ValidateLogDir)
+type ValidationReport struct {
+	Sessions []SessionReport
+}
+
+// SessionReport describes the chunk files created by a single HugeLogger
+type SessionReport struct {
+	Prefix  string // Prefix is the file prefix shared by all the chunks of the session
+	Chunks  []ChunkReport
+	Missing []int // Missing holds the indexes of the chunks not found on disk
+	Meta    bool  // Meta reports whether the session has a sidecar file
+	// StaleMeta reports whether the sidecar file does not match
+	// the logs found in the chunks (for example after a crash)
+	StaleMeta bool
+}
+
+// ChunkReport describes a single chunk file
+type ChunkReport struct {
+	Index int
+	File  string
+	Lines int   // Lines is the number of non-empty lines in the file
+	Logs  int   // Logs is the number of lines that could be decoded into a Log
+	Err   error // Err is the first error encountered while reading the file, if any
+}
+
+// Healthy reports whether every session has all of its chunks,
+// every chunk could be completely decoded and no sidecar file is stale
+func (r ValidationReport) Healthy() bool {
+	for _, session := range r.Sessions {
+		if len(session.Missing) != 0 || session.StaleMeta {
+			return false
+		}
+
+		for _, chunk := range session.Chunks {
+			if chunk.Err != nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ValidateLogDir checks the log files created in dir by any HugeLogger
+// with the given prefix, without modifying them: for every session it reports
+// the gaps in the chunk numbering and, for every chunk, how many logs
+// could be decoded and the first decoding error. The chunks deleted by the
+// retention or by TruncateToLast, as recorded by the sidecar file, are not
+// reported as missing. The returned error is only about the directory
+// itself, any other problem is in the report
+func ValidateLogDir(dir, prefix string) (report ValidationReport, err error) {
+	sessions, err := findChunkSessions(dir, prefix)
+	if err != nil {
+		return
+	}
+
+	for _, session := range sessions {
+		sr := SessionReport{ Prefix: session.prefix }
+
+		meta, metaErr := readMeta(dir, session.prefix)
+		next := 0
+		if metaErr == nil {
+			next = meta.firstChunk()
+		}
+
+		for _, chunk := range session.chunks {
+			for ; next < chunk.index; next++ {
+				sr.Missing = append(sr.Missing, next)
+			}
+			next = chunk.index + 1
+
+			sr.Chunks = append(sr.Chunks, validateChunk(chunk))
+		}
+
+		if metaErr == nil {
+			sr.Meta = true
+			sr.StaleMeta = metaIsStale(meta, sr)
+		}
+
+		report.Sessions = append(report.Sessions, sr)
+	}
+
+	return
+}
+
+// metaIsStale reports whether the sidecar file of a session disagrees with the
+// chunks found on disk, from the first one not deleted by the retention or by
+// TruncateToLast. The last chunk may have more logs than recorded, since the
+// sidecar file is only updated on rollover and on close
+func metaIsStale(meta logMeta, sr SessionReport) bool {
+	if len(meta.Counts) != meta.Chunks {
+		return true
+	}
+
+	first := meta.firstChunk()
+	found := len(sr.Missing)
+	for _, chunk := range sr.Chunks {
+		if chunk.Index < first {
+			// left by a failed deletion, its logs are unavailable anyway
+			continue
+		}
+		if chunk.Index >= meta.Chunks {
+			return true
+		}
+		found ++
+
+		count := meta.Counts[chunk.Index]
+		if chunk.Logs < count || (chunk.Index != meta.Chunks-1 && chunk.Logs != count) {
+			return true
+		}
+	}
+	return found != meta.Chunks - first
+}
+
+func validateChunk(chunk chunkFile) ChunkReport {
+	cr := ChunkReport{ Index: chunk.index, File: chunk.path }
+
+	f, err := os.Open(chunk.path)
+	if err != nil {
+		cr.Err = err
+		return cr
+	}
+	defer f.Close()
+
+	compressed := strings.HasSuffix(chunk.path, "." + CompressedFileExtension)
+	err = scanChunk(f, compressed, chunk.index, func(sc *bufio.Scanner) error {
+		for sc.Scan() {
+			if len(sc.Bytes()) == 0 {
+				continue
+			}
+			cr.Lines++
+
+			var l Log
+			if err := json.Unmarshal(sc.Bytes(), &l); err != nil {
+				if cr.Err == nil {
+					cr.Err = fmt.Errorf("line %d: %w", cr.Lines, err)
+				}
+				continue
+			}
+			cr.Logs++
+		}
+		return sc.Err()
+	})
+	if err != nil && cr.Err == nil {
+		cr.Err = err
+	}
+
+	return cr
+}
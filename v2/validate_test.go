@@ -0,0 +1,82 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestValidateCompressedChunks(t *testing.T) {
+	setChunkSize(t, 10)
+	l, dir := newTestHugeLogger(t)
+	l.SetCompressRotated(true)
+
+	for i := 0; i < 35; i++ {
+		l.AddLog(LOG_LEVEL_INFO, fmt.Sprintf("log %d", i), "", false)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := ValidateLogDir(dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Healthy() {
+		t.Fatalf("report not healthy: %+v", report)
+	}
+
+	compressed := 0
+	for _, chunk := range report.Sessions[0].Chunks {
+		if strings.HasSuffix(chunk.File, "." + CompressedFileExtension) {
+			compressed ++
+			if chunk.Logs != 10 {
+				t.Errorf("compressed chunk %d has %d logs, want 10", chunk.Index, chunk.Logs)
+			}
+		}
+	}
+	if compressed != 3 {
+		t.Errorf("found %d compressed chunks, want 3", compressed)
+	}
+}
+
+func TestValidateAfterRetention(t *testing.T) {
+	for _, truncate := range []bool{ false, true } {
+		t.Run(fmt.Sprintf("truncate=%v", truncate), func(t *testing.T) {
+			setChunkSize(t, 10)
+			l, dir := newTestHugeLogger(t)
+			if !truncate {
+				l.SetRetention(2)
+			}
+
+			for i := 0; i < 55; i++ {
+				l.AddLog(LOG_LEVEL_INFO, fmt.Sprintf("log %d", i), "", false)
+			}
+			if truncate {
+				if err := l.TruncateToLast(12); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := l.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			report, err := ValidateLogDir(dir, "test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !report.Healthy() {
+				t.Fatalf("report not healthy: %+v", report)
+			}
+
+			session := report.Sessions[0]
+			var chunks []int
+			for _, chunk := range session.Chunks {
+				chunks = append(chunks, chunk.Index)
+			}
+			if fmt.Sprint(chunks) != "[4 5]" || len(session.Missing) != 0 {
+				t.Errorf("chunks %v and missing %v, want the chunks [4 5] and none missing", chunks, session.Missing)
+			}
+		})
+	}
+}
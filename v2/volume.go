@@ -0,0 +1,88 @@
+package logger
+
+import (
+	"sync"
+	"time"
+)
+
+// volumeAlert detects when too many logs are created in a short
+// time (see SetVolumeAlert): it keeps the creation time of the last
+// threshold+1 logs, so that the check costs the same for every log
+type volumeAlert struct {
+	vm        sync.Mutex
+	threshold int
+	window    time.Duration
+	fn        func(count int)
+	start     time.Time
+	times     []time.Duration
+	next      int
+	fired     time.Duration
+	hasFired  bool
+}
+
+// SetVolumeAlert calls fn when more than threshold logs are created
+// by the Logger (including the ones created by its clones) within the
+// last window, for example to detect a log storm and react by raising
+// the min level; RollingStats can then tell which levels are causing
+// it. The alert fires as soon as the threshold is crossed, so fn
+// receives threshold+1 as the number of logs in the window; it's called
+// at most once per window, even if the storm goes on. It's called
+// synchronously on the goroutine creating the log that crosses the
+// threshold, after the log is stored and without holding any lock of the
+// Logger, so it can change the Logger settings, but it should return
+// quickly (or start a goroutine) since it holds up that log. A threshold
+// less than or equal to zero, a non-positive window or a nil fn remove
+// the alert. The logs created before the call are not counted
+func (va *volumeAlert) SetVolumeAlert(threshold int, window time.Duration, fn func(count int)) {
+	va.vm.Lock()
+	defer va.vm.Unlock()
+
+	if threshold <= 0 || window <= 0 || fn == nil {
+		va.fn = nil
+		va.times = nil
+		return
+	}
+
+	va.threshold = threshold
+	va.window = window
+	va.fn = fn
+	va.start = time.Now()
+	va.times = make([]time.Duration, 0, threshold + 1)
+	va.next = 0
+	va.hasFired = false
+}
+
+// checkVolume records a new log and calls the alert
+// callback if the threshold was crossed
+func (va *volumeAlert) checkVolume() {
+	va.vm.Lock()
+	if va.fn == nil {
+		va.vm.Unlock()
+		return
+	}
+
+	now := time.Since(va.start)
+	if len(va.times) < cap(va.times) {
+		va.times = append(va.times, now)
+	} else {
+		va.times[va.next] = now
+		va.next = (va.next + 1) % len(va.times)
+	}
+
+	// the oldest of the last threshold+1 logs
+	// must fall within the window
+	if len(va.times) < cap(va.times) || now - va.times[va.next] > va.window {
+		va.vm.Unlock()
+		return
+	}
+	if va.hasFired && now - va.fired < va.window {
+		va.vm.Unlock()
+		return
+	}
+	va.fired, va.hasFired = now, true
+
+	fn, count := va.fn, len(va.times)
+	va.vm.Unlock()
+
+	fn(count)
+}